@@ -0,0 +1,300 @@
+package AudioSegment
+
+import (
+	"fmt"
+	"math"
+)
+
+type SegmentStats struct {
+	DurationMs int
+	DBFS       float64
+	MaxDBFS    float64
+	RMS        float64
+	// mean of each channel, normalized to -1..1
+	DCOffset       []float64
+	ClippedSamples int
+}
+
+func ratio_to_db(ratio float64) float64 {
+	if ratio == 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(ratio)
+}
+
+// Analyze gathers the levels of the segment in a single pass over its samples.
+func (p *AudioSegment) Analyze() SegmentStats {
+	channels := int(p.channels)
+	width := int(p.sample_width)
+	data := *p.data
+	count := len(data) / width
+	min_sample, max_sample := p.min_sample(), p.max_sample()
+
+	sums := make([]float64, channels)
+	var square_sum float64
+	var peak int64
+	clipped := 0
+	for i := 0; i < count; i++ {
+		v := int64(decode_sample(data[i*width:], p.sample_width))
+		sums[i%channels] += float64(v)
+		square_sum += float64(v) * float64(v)
+		if v == min_sample || v == max_sample {
+			clipped++
+		}
+		if v < 0 {
+			v = -v
+		}
+		if v > peak {
+			peak = v
+		}
+	}
+
+	stats := SegmentStats{
+		DurationMs:     p.Len(),
+		DCOffset:       make([]float64, channels),
+		ClippedSamples: clipped,
+	}
+	max_amplitude := p.max_possible_amplitude()
+	if count > 0 {
+		stats.RMS = math.Sqrt(square_sum / float64(count))
+		frames := float64(count / channels)
+		for c := range sums {
+			stats.DCOffset[c] = sums[c] / frames / max_amplitude
+		}
+	}
+	stats.DBFS = ratio_to_db(stats.RMS / max_amplitude)
+	stats.MaxDBFS = ratio_to_db(float64(peak) / max_amplitude)
+	return stats
+}
+
+// MonoCompatibility compares the energy of the mono fold-down with the mean
+// energy of the channels: 1 means nothing cancels when summed to mono, 0 means
+// the channels cancel out completely. Mono segments score 1.
+func (p *AudioSegment) MonoCompatibility() float64 {
+	if p.channels == 1 {
+		return 1
+	}
+	// the mean over all samples is the mean of the channel energies
+	energy := p.RMS() * p.RMS()
+	if energy == 0 {
+		return 1
+	}
+	rms := p.SetChannels(1).RMS()
+	return math.Min(rms*rms/energy, 1)
+}
+
+const (
+	true_peak_oversampling = 4
+	true_peak_taps         = 16
+)
+
+// true_peak_kernel holds a Hann windowed sinc interpolator per fractional phase
+func true_peak_kernel() [][]float64 {
+	kernel := make([][]float64, true_peak_oversampling)
+	for phase := range kernel {
+		frac := float64(phase) / true_peak_oversampling
+		taps := make([]float64, 2*true_peak_taps)
+		var sum float64
+		for k := range taps {
+			x := float64(k-true_peak_taps+1) - frac
+			sinc := 1.0
+			if x != 0 {
+				sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			window := 0.5 + 0.5*math.Cos(math.Pi*x/float64(true_peak_taps))
+			taps[k] = sinc * window
+			sum += taps[k]
+		}
+		for k := range taps {
+			taps[k] /= sum
+		}
+		kernel[phase] = taps
+	}
+	return kernel
+}
+
+// TruePeakDBFS measures the peak of the signal oversampled 4 times, which
+// catches the inter-sample overs a plain sample peak misses.
+func (p *AudioSegment) TruePeakDBFS() float64 {
+	kernel := true_peak_kernel()
+	var peak float64
+	for _, samples := range p.channel_floats() {
+		for i := range samples {
+			for phase, taps := range kernel {
+				var v float64
+				if phase == 0 {
+					v = samples[i]
+				} else {
+					for k, tap := range taps {
+						j := i + k - true_peak_taps + 1
+						if j >= 0 && j < len(samples) {
+							v += samples[j] * tap
+						}
+					}
+				}
+				peak = math.Max(peak, math.Abs(v))
+			}
+		}
+	}
+	return ratio_to_db(peak / p.max_possible_amplitude())
+}
+
+const zero_crossing_window_ms = 10
+
+// NearestZeroCrossing returns the position in ms of the zero crossing of
+// channel closest to ms, searching zero_crossing_window_ms either way. ms is
+// returned unchanged when the window holds no crossing.
+func (p *AudioSegment) NearestZeroCrossing(ms int, channel int) int {
+	if channel < 0 || channel >= int(p.channels) {
+		errmsg := fmt.Sprintf("Channel %d out of range for %d channels", channel, p.channels)
+		panic(errmsg)
+	}
+	frames := p.FrameCount()
+	width := int(p.sample_width)
+	sample := func(frame int) int32 {
+		return decode_sample((*p.data)[frame*int(p.frame_width)+channel*width:], p.sample_width)
+	}
+	// a crossing at frame i lies between frames i-1 and i
+	crosses := func(frame int) bool {
+		if frame < 1 || frame >= frames {
+			return false
+		}
+		a, b := sample(frame-1), sample(frame)
+		return b == 0 || (a < 0) != (b < 0)
+	}
+
+	center := p.FrameCountMs(ms)
+	window := p.FrameCountMs(zero_crossing_window_ms)
+	for d := 0; d <= window; d++ {
+		if crosses(center - d) {
+			return p.frame_to_ms(center - d)
+		}
+		if crosses(center + d) {
+			return p.frame_to_ms(center + d)
+		}
+	}
+	return ms
+}
+
+// ApproxEqual compares the samples of both segments normalized to -1..1 and
+// reports whether none differ by more than tolerance. Segments of a different
+// rate, channel count or length never match; sample widths may differ.
+func (p *AudioSegment) ApproxEqual(other *AudioSegment, tolerance float64) bool {
+	if p.frame_rate != other.frame_rate || p.channels != other.channels || p.FrameCount() != other.FrameCount() {
+		return false
+	}
+	a, b := p.GetFloatSamples(), other.GetFloatSamples()
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+type EnvelopeDetector int
+
+const (
+	PeakDetector EnvelopeDetector = iota
+	RMSDetector
+)
+
+// Envelope returns the linear 0..1 level of every windowMs window, the last
+// one possibly shorter
+func (p *AudioSegment) Envelope(windowMs int, detector EnvelopeDetector) []float64 {
+	size := p.FrameCountMs(windowMs)
+	if size < 1 {
+		errmsg := fmt.Sprintf("Envelope window of %dms is too short", windowMs)
+		panic(errmsg)
+	}
+	frames := p.FrameCount()
+	envelope := make([]float64, 0, (frames+size-1)/size)
+	for start := 0; start < frames; start += size {
+		end := start + size
+		if end > frames {
+			end = frames
+		}
+		level := p.peak_frames(start, end)
+		if detector == RMSDetector {
+			level = p.rms_frames(start, end)
+		}
+		envelope = append(envelope, level/p.max_possible_amplitude())
+	}
+	return envelope
+}
+
+// Balance compares the energy of the left and right channels of a stereo
+// segment: -1 is all left, 1 all right and 0 centered or silent
+func (p *AudioSegment) Balance() float64 {
+	if p.channels != 2 {
+		errmsg := fmt.Sprintf("Balance needs stereo audio, got %d channels", p.channels)
+		panic(errmsg)
+	}
+	var left, right float64
+	channels := p.channel_floats()
+	for i := range channels[0] {
+		left += channels[0][i] * channels[0][i]
+		right += channels[1][i] * channels[1][i]
+	}
+	if left+right == 0 {
+		return 0
+	}
+	return (right - left) / (right + left)
+}
+
+func crest_factor(peak, rms float64) float64 {
+	if rms == 0 {
+		return 0
+	}
+	return ratio_to_db(peak / rms)
+}
+
+// CrestFactor is the peak to RMS ratio in dB, low values point to heavily
+// compressed audio. Silence measures 0.
+func (p *AudioSegment) CrestFactor() float64 {
+	return crest_factor(p.peak_frames(0, p.FrameCount()), p.RMS())
+}
+
+// ChannelCrestFactors is CrestFactor for every channel on its own
+func (p *AudioSegment) ChannelCrestFactors() []float64 {
+	factors := make([]float64, p.channels)
+	for c, samples := range p.channel_floats() {
+		var peak, sum float64
+		for _, v := range samples {
+			peak = math.Max(peak, math.Abs(v))
+			sum += v * v
+		}
+		rms := 0.0
+		if len(samples) > 0 {
+			rms = math.Sqrt(sum / float64(len(samples)))
+		}
+		factors[c] = crest_factor(peak, rms)
+	}
+	return factors
+}
+
+// PeakProfile splits the segment into buckets even spans and returns the 0..1
+// peak of every span for each channel, the data behind a waveform drawing
+func (p *AudioSegment) PeakProfile(buckets int) [][]float64 {
+	if buckets < 1 {
+		errmsg := fmt.Sprintf("Invalid peak profile of %d buckets", buckets)
+		panic(errmsg)
+	}
+	frames := p.FrameCount()
+	scale := p.max_possible_amplitude()
+	width := int(p.sample_width)
+	profile := make([][]float64, p.channels)
+	for c := range profile {
+		profile[c] = make([]float64, buckets)
+	}
+	for b := 0; b < buckets; b++ {
+		start, end := b*frames/buckets, (b+1)*frames/buckets
+		for i := start; i < end; i++ {
+			for c := range profile {
+				v := decode_sample((*p.data)[i*int(p.frame_width)+c*width:], p.sample_width)
+				profile[c][b] = math.Max(profile[c][b], math.Abs(float64(v))/scale)
+			}
+		}
+	}
+	return profile
+}
@@ -3,11 +3,15 @@ package AudioSegment
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"github.com/cryptix/wav"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
 type WavSubChunk struct {
@@ -16,12 +20,18 @@ type WavSubChunk struct {
 	size     uint32
 }
 
+type WavChunk struct {
+	id   []byte
+	data []byte
+}
+
 type WavData struct {
 	audio_format    uint16
 	channels        uint16
 	sample_rate     uint32
 	bits_per_sample uint16
 	raw_data        []byte
+	chunks          []WavChunk
 }
 
 type AudioSegment struct {
@@ -30,6 +40,74 @@ type AudioSegment struct {
 	frame_rate   uint32
 	frame_width  uint16
 	sample_width uint16
+	// wav chunks other than fmt/data found while parsing, kept for export
+	chunks []WavChunk
+	// loop region in frames, unset when loop_end <= loop_start
+	loop_start int
+	loop_end   int
+	// cue points and smpl loop boundaries of the source wav, in frames
+	markers []Marker
+	// shared with the views of data, so the in-place transforms know to
+	// copy it first
+	owner *data_owner
+}
+
+// data_owner is shared by a segment and every view made of its data
+type data_owner struct {
+	// updated atomically, views may be made from several goroutines
+	views int32
+}
+
+const (
+	WavFormatPCM        uint16 = 0x0001
+	WavFormatFloat      uint16 = 0x0003
+	WavFormatALaw       uint16 = 0x0006
+	WavFormatMuLaw      uint16 = 0x0007
+	WavFormatExtensible uint16 = 0xFFFE
+)
+
+type ExportOptions struct {
+	// re-emit the chunks (bext, LIST, ...) read from the source wav. The cue
+	// and smpl chunks are written from the markers and loop points instead,
+	// inst chunks are dropped
+	PreserveChunks bool
+	// format tag written in the fmt chunk, WavFormatPCM when zero.
+	// WavFormatALaw and WavFormatMuLaw compand the samples to 8 bits
+	FormatTag uint16
+	// write a big endian RIFX file instead of RIFF
+	BigEndian bool
+	// write 32-bit IEEE float samples
+	FloatOutput bool
+	// fades applied to the exported copy, zero means no fade
+	FadeInMs  int
+	FadeOutMs int
+	// write a fact chunk with the frame count, which some readers require
+	// for formats other than PCM
+	WriteFactChunk bool
+	// LIST/INFO tags to write, they replace the preserved INFO tags
+	InfoTags map[string]string
+	// libvorbis VBR quality of ogg exports, -1..10. 0 keeps the encoder
+	// default (3) unless VorbisQualitySet asks for quality 0.
+	VorbisQuality    float64
+	VorbisQualitySet bool
+	// reduce the sample width to this many bytes with noise shaped TPDF
+	// dither instead of truncating, zero keeps the width
+	DitherToWidth uint16
+	// seed of the dither noise, the same seed gives the same output
+	DitherSeed int64
+}
+
+func (p *AudioSegment) Channels() uint16 {
+	return p.channels
+}
+
+func (p *AudioSegment) FrameRate() uint32 {
+	return p.frame_rate
+}
+
+// SampleWidth is the size of a sample in bytes
+func (p *AudioSegment) SampleWidth() uint16 {
+	return p.sample_width
 }
 
 func (p *AudioSegment) FrameCount() int {
@@ -37,16 +115,55 @@ func (p *AudioSegment) FrameCount() int {
 }
 
 func (p *AudioSegment) FrameCountMs(ms int) int {
-	return ms * (int(p.frame_rate) / 1000.0)
+	return int(float64(ms) * float64(p.frame_rate) / 1000.0)
 }
 
 func (p *AudioSegment) Len() int {
 	return int(math.Round(1000 * float64(p.FrameCount()) / float64(p.frame_rate)))
 }
 
+// Duration is the exact length of the segment, Len rounds it to ms
+func (p *AudioSegment) Duration() time.Duration {
+	return time.Duration(int64(p.FrameCount()) * int64(time.Second) / int64(p.frame_rate))
+}
+
+// LenFrames is the length of the segment in frames, without the ms rounding
+// of Len
+func (p *AudioSegment) LenFrames() int {
+	return p.FrameCount()
+}
+
+func (p *AudioSegment) NumFrames() int {
+	return p.FrameCount()
+}
+
+// NumSamples counts the samples of every channel, FrameCount() * channels
+func (p *AudioSegment) NumSamples() int {
+	return p.FrameCount() * int(p.channels)
+}
+
+// Slice cuts bytes start:end of the audio data, SliceFrames works in frames.
+// The loop points and markers only survive a cut on frame boundaries.
 func (p *AudioSegment) Slice(start, end int) *AudioSegment {
 	data := (*p.data)[start:end]
-	return p.spawn(&data)
+	as := p.view(&data)
+	frame_width := int(p.frame_width)
+	if start%frame_width != 0 || end%frame_width != 0 {
+		as.loop_start, as.loop_end = 0, 0
+		as.markers = nil
+		return as
+	}
+	return p.slice_loop(as, start/frame_width, end/frame_width)
+}
+
+// SliceFrames returns frames [start, end) of the segment
+func (p *AudioSegment) SliceFrames(start, end int) *AudioSegment {
+	if start < 0 || end < start || end > p.FrameCount() {
+		errmsg := fmt.Sprintf("Invalid frame range %d-%d for %d frames", start, end, p.FrameCount())
+		panic(errmsg)
+	}
+	data := (*p.data)[start*int(p.frame_width) : end*int(p.frame_width)]
+	return p.slice_loop(p.view(&data), start, end)
 }
 
 func (p *AudioSegment) parsePosition(val int) int {
@@ -58,68 +175,581 @@ func (p *AudioSegment) parsePosition(val int) int {
 }
 
 func (p *AudioSegment) Overlay(seg *AudioSegment) *AudioSegment {
-	return p
+	return p.overlay_frames(seg, 0, 0, seg.FrameCount())
 }
 
-func (p *AudioSegment) Fade() *AudioSegment {
-	return p
+// OverlayRange mixes the first lengthMs of seg in at atMs, both rounded down to
+// frames. OverlayAtFrame places seg with sample accuracy.
+func (p *AudioSegment) OverlayRange(seg *AudioSegment, atMs, lengthMs int) *AudioSegment {
+	if atMs < 0 {
+		atMs = 0
+	}
+	if lengthMs < 0 {
+		lengthMs = 0
+	}
+	return p.overlay_frames(seg, p.FrameCountMs(atMs), 0, seg.FrameCountMs(lengthMs))
 }
 
+// OverlayAtFrame mixes seg in starting at frame, cut at the end of p
+func (p *AudioSegment) OverlayAtFrame(seg *AudioSegment, frame int) *AudioSegment {
+	if frame < 0 {
+		errmsg := fmt.Sprintf("Invalid overlay position frame %d", frame)
+		panic(errmsg)
+	}
+	return p.overlay_frames(seg, frame, 0, seg.FrameCount())
+}
 
+// overlay_frames mixes frames of seg starting at seg_start into a copy of p
+// starting at frame at, clamped to both segments
+func (p *AudioSegment) overlay_frames(seg *AudioSegment, at, seg_start, frames int) *AudioSegment {
+	if err := p.check_compatible(seg); err != nil {
+		panic(err)
+	}
+	if seg_start+frames > seg.FrameCount() {
+		frames = seg.FrameCount() - seg_start
+	}
+	if at+frames > p.FrameCount() {
+		frames = p.FrameCount() - at
+	}
 
-func (p *AudioSegment) AppendCrossfage(seg *AudioSegment, crossfade int) *AudioSegment {
-	//TODO: need to sync two audiosegment
-	seg1 := p
-	seg2 := seg
-
-	if crossfade == 0 {
-		data := append(*p.data, *seg.data...)
+	data := make([]byte, len(*p.data))
+	copy(data, *p.data)
+	if frames <= 0 {
 		return p.spawn(&data)
-	} else if crossfade > p.Len() {
-		errmsg := fmt.Sprintf("Crossfade is longer than the original AudioSegment (%dms > %dms)", crossfade, p.Len())
+	}
+	width := int(p.sample_width)
+	base := data[at*int(p.frame_width) : (at+frames)*int(p.frame_width)]
+	other := (*seg.data)[seg_start*int(seg.frame_width) : (seg_start+frames)*int(seg.frame_width)]
+	for i := 0; i < len(base); i += width {
+		v := int64(decode_sample(base[i:], p.sample_width)) + int64(decode_sample(other[i:], p.sample_width))
+		encode_sample(base[i:], p.sample_width, p.saturate(v))
+	}
+	return p.spawn(&data)
+}
+
+// FadeCurve maps the 0..1 progress through a fade to a 0..1 gain
+type FadeCurve func(progress float64) float64
+
+var (
+	Linear FadeCurve = func(progress float64) float64 {
+		return progress
+	}
+	// LinearDB ramps the gain evenly in dB, from -120dB to 0dB
+	LinearDB FadeCurve = func(progress float64) float64 {
+		if progress <= 0 {
+			return 0
+		}
+		return db_to_ratio(-120 * (1 - progress))
+	}
+	EqualPower FadeCurve = func(progress float64) float64 {
+		return math.Sin(progress * math.Pi / 2)
+	}
+)
+
+func reversed(curve FadeCurve) FadeCurve {
+	return func(progress float64) float64 {
+		return curve(1 - progress)
+	}
+}
+
+// Fade applies curve between startMs and endMs. Audio before startMs gets the
+// gain curve(0), audio after endMs the gain curve(1).
+func (p *AudioSegment) Fade(startMs, endMs int, curve FadeCurve) *AudioSegment {
+	if startMs < 0 || endMs < startMs {
+		errmsg := fmt.Sprintf("Invalid fade range %dms-%dms", startMs, endMs)
 		panic(errmsg)
-	} else if crossfade > seg.Len() {
-		errmsg := fmt.Sprintf("Crossfade is longer than the appended AudioSegment (%dms > %dms)", crossfade, seg.Len())
+	}
+	return p.fade_frames(p.FrameCountMs(startMs), p.FrameCountMs(endMs), curve)
+}
+
+func (p *AudioSegment) fade_frames(start, end int, curve FadeCurve) *AudioSegment {
+	channels := int(p.channels)
+	samples := p.samples()
+	before, after := curve(0), curve(1)
+	for i, v := range samples {
+		frame := i / channels
+		gain := after
+		if frame < start {
+			gain = before
+		} else if frame < end {
+			gain = curve(float64(frame-start) / float64(end-start))
+		}
+		samples[i] = p.saturate_float(float64(v) * gain)
+	}
+	return p.spawn_samples(samples)
+}
+
+func (p *AudioSegment) FadeIn(durationMs int) *AudioSegment {
+	return p.Fade(0, durationMs, Linear)
+}
+
+func (p *AudioSegment) FadeOut(durationMs int) *AudioSegment {
+	start := p.Len() - durationMs
+	if start < 0 {
+		start = 0
+	}
+	return p.Fade(start, p.Len(), reversed(Linear))
+}
+
+func (p *AudioSegment) SetFrameRate(frameRate uint32) *AudioSegment {
+	if frameRate == 0 {
+		panic("Frame rate must be positive")
+	}
+	if frameRate == p.frame_rate {
+		return p.spawn_channel_floats(p.channel_floats())
+	}
+	out_frames := int(math.Round(float64(p.FrameCount()) * float64(frameRate) / float64(p.frame_rate)))
+	as := p.resample_frames(out_frames)
+	as.frame_rate = frameRate
+	return as
+}
+
+// Reinterpret relabels the format of the segment without touching its bytes,
+// for files whose header is wrong. Use SetFrameRate to convert instead.
+func (p *AudioSegment) Reinterpret(frameRate uint32, channels uint16, sampleWidth uint16) *AudioSegment {
+	if frameRate == 0 || channels == 0 || sampleWidth < 1 || sampleWidth > 4 {
+		errmsg := fmt.Sprintf("Invalid format %d channels, %dHz, %d bytes per sample", channels, frameRate, sampleWidth)
 		panic(errmsg)
 	}
+	if len(*p.data)%int(channels*sampleWidth) != 0 {
+		errmsg := fmt.Sprintf("%d bytes of audio can't be split into frames of %d bytes", len(*p.data), channels*sampleWidth)
+		panic(errmsg)
+	}
+	as := p.view(p.data)
+	as.frame_rate = frameRate
+	as.channels = channels
+	as.sample_width = sampleWidth
+	as.frame_width = channels * sampleWidth
+	if as.frame_width != p.frame_width {
+		as.loop_start, as.loop_end = 0, 0
+		as.markers = nil
+	}
+	return as
+}
 
-	xf := p.Slice(-crossfade, p.Len()).Fade()
-	xf.Overlay(seg.Slice(0, crossfade).Fade())
+// resample_frames linearly interpolates the segment onto out_frames frames
+// spanning the same audio
+func (p *AudioSegment) resample_frames(out_frames int) *AudioSegment {
+	in_frames := p.FrameCount()
+	channels := p.channel_floats()
+	out := make([][]float64, len(channels))
+	step := 0.0
+	if out_frames > 0 {
+		step = float64(in_frames) / float64(out_frames)
+	}
+	for c, samples := range channels {
+		resampled := make([]float64, out_frames)
+		for i := range resampled {
+			pos := float64(i) * step
+			j := int(pos)
+			if j+1 >= in_frames {
+				resampled[i] = samples[in_frames-1]
+				continue
+			}
+			frac := pos - float64(j)
+			resampled[i] = samples[j]*(1-frac) + samples[j+1]*frac
+		}
+		out[c] = resampled
+	}
+	return p.scale_loop(p.spawn_channel_floats(out))
+}
 
+// MaxCrossfade is the longest crossfade AppendCrossfade accepts between p and other
+func (p *AudioSegment) MaxCrossfade(other *AudioSegment) int {
+	if other.Len() < p.Len() {
+		return other.Len()
+	}
+	return p.Len()
 }
 
-func (p *AudioSegment) Append(seg *AudioSegment) *AudioSegment {
-	return p.AppendCrossfage(seg, 0)
+type CrossfadeOptions struct {
+	// move the join by up to MaxSyncMs to where the overlapping audio of both
+	// segments correlates best, so beats and transients line up
+	AutoSync bool
+	// half the crossfade when zero
+	MaxSyncMs int
 }
 
-func (p *AudioSegment) saveWav(file *os.File) {
-	meta := wav.File{
-		Channels:        p.channels,
-		SampleRate:      p.frame_rate,
-		SignificantBits: p.sample_width * 8,
+func (p *AudioSegment) AppendCrossfade(seg *AudioSegment, crossfade int) (*AudioSegment, error) {
+	return p.AppendCrossfadeWithOptions(seg, crossfade, CrossfadeOptions{})
+}
+
+// AppendCrossfadeWithOptions is AppendCrossfade with the join optionally
+// synced, the overlap then grows or shrinks by the shift
+func (p *AudioSegment) AppendCrossfadeWithOptions(seg *AudioSegment, crossfade int, opts CrossfadeOptions) (*AudioSegment, error) {
+	if err := p.check_compatible(seg); err != nil {
+		return nil, err
+	}
+	if crossfade < 0 {
+		return nil, fmt.Errorf("Invalid crossfade %dms", crossfade)
+	} else if crossfade > p.Len() {
+		return nil, fmt.Errorf("Crossfade is longer than the original AudioSegment (%dms > %dms)", crossfade, p.Len())
+	} else if crossfade > seg.Len() {
+		return nil, fmt.Errorf("Crossfade is longer than the appended AudioSegment (%dms > %dms)", crossfade, seg.Len())
+	} else if opts.MaxSyncMs < 0 {
+		return nil, fmt.Errorf("Invalid crossfade sync range %dms", opts.MaxSyncMs)
 	}
-	writer, err := meta.NewWriter(file)
+
+	xf := p.FrameCountMs(crossfade)
+	if xf > p.FrameCount() {
+		xf = p.FrameCount()
+	}
+	if xf > seg.FrameCount() {
+		xf = seg.FrameCount()
+	}
+	if opts.AutoSync && xf > 0 {
+		xf = p.synced_crossfade(seg, xf, opts.MaxSyncMs)
+	}
+	return p.append_crossfade_frames(seg, xf), nil
+}
+
+// synced_crossfade returns the overlap, within max_sync_ms of xf frames,
+// at which the head of seg best continues the end of p
+func (p *AudioSegment) synced_crossfade(seg *AudioSegment, xf int, max_sync_ms int) int {
+	shift := xf / 2
+	if max_sync_ms > 0 {
+		shift = p.FrameCountMs(max_sync_ms)
+	}
+	// the overlap may grow by up to grow frames and shrink by up to shrink
+	grow := shift
+	if p.FrameCount()-xf < grow {
+		grow = p.FrameCount() - xf
+	}
+	if seg.FrameCount()-xf < grow {
+		grow = seg.FrameCount() - xf
+	}
+	shrink := shift
+	if xf-1 < shrink {
+		shrink = xf - 1
+	}
+	// lag 0 starts seg grow frames before the nominal overlap
+	tail := p.mono_frames(p.FrameCount()-xf-grow, p.FrameCount())
+	head := seg.mono_frames(0, xf)
+	lag := best_lag(tail, head, 0, grow+shrink)
+	return xf + grow - lag
+}
+
+func (p *AudioSegment) append_crossfade_frames(seg *AudioSegment, xf int) *AudioSegment {
+	frame_width := int(p.frame_width)
+	head_size := (p.FrameCount() - xf) * frame_width
+	xf_size := xf * frame_width
+
+	tail_data := (*p.data)[head_size:]
+	head_data := (*seg.data)[:xf_size]
+	tail := p.spawn(&tail_data).fade_frames(0, xf, reversed(Linear))
+	head := seg.spawn(&head_data).fade_frames(0, xf, Linear)
+
+	data := make([]byte, 0, len(*p.data)+len(*seg.data)-xf_size)
+	data = append(data, (*p.data)[:head_size]...)
+	data = append(data, *tail.Overlay(head).data...)
+	data = append(data, (*seg.data)[xf_size:]...)
+	as := p.spawn(&data)
+	as.markers = append(p.slice_markers(0, p.FrameCount()), seg.moved_markers(0, p.FrameCount()-xf)...)
+	return as
+}
+
+// CrossfadeJoin cuts out startMs-endMs and crossfades the audio on either
+// side over crossfadeMs centered on the cut, so the result is exactly the cut
+// region shorter
+func (p *AudioSegment) CrossfadeJoin(startMs, endMs, crossfadeMs int) *AudioSegment {
+	start, end := p.FrameCountMs(startMs), p.FrameCountMs(endMs)
+	frames := p.FrameCount()
+	if startMs < 0 || end < start || end > frames || crossfadeMs < 0 {
+		errmsg := fmt.Sprintf("Invalid join of %dms-%dms with a %dms crossfade", startMs, endMs, crossfadeMs)
+		panic(errmsg)
+	}
+	half := p.FrameCountMs(crossfadeMs) / 2
+	if half > start || half > frames-end {
+		errmsg := fmt.Sprintf("Crossfade of %dms doesn't fit around the join of %dms-%dms", crossfadeMs, startMs, endMs)
+		panic(errmsg)
+	}
+	frame_width := int(p.frame_width)
+	head_data := (*p.data)[:(start+half)*frame_width]
+	tail_data := (*p.data)[(end-half)*frame_width:]
+	head := p.slice_loop(p.spawn(&head_data), 0, start+half)
+	as := head.append_crossfade_frames(p.spawn(&tail_data), 2*half)
+	as.markers = p.moved_markers(end, start-end)
+	return as
+}
+
+// Append joins seg after the segment, both must share a format; ConcatAll
+// converts differing ones first, promoting to the higher sample width
+func (p *AudioSegment) Append(seg *AudioSegment) *AudioSegment {
+	as, err := p.AppendCrossfade(seg, 0)
 	if err != nil {
 		panic(err)
 	}
-	defer writer.Close()
-	writer.Write(*p.data)
+	return as
+}
+
+// Prepend puts seg in front of the segment, which keeps its metadata with the
+// loop points and markers moved along
+func (p *AudioSegment) Prepend(seg *AudioSegment) *AudioSegment {
+	if err := p.check_compatible(seg); err != nil {
+		panic(err)
+	}
+	return p.prepend_data(*seg.data)
+}
+
+func (p *AudioSegment) PrependSilence(ms int) *AudioSegment {
+	if ms < 0 {
+		errmsg := fmt.Sprintf("Invalid silence duration %dms", ms)
+		panic(errmsg)
+	}
+	return p.prepend_data(p.silent_data(p.FrameCountMs(ms)))
+}
+
+// Insert splices seg in at atMs, pushing the rest of the segment back. atMs
+// at or past the end appends, the loop points and markers move with the audio.
+func (p *AudioSegment) Insert(seg *AudioSegment, atMs int) *AudioSegment {
+	if err := p.check_compatible(seg); err != nil {
+		panic(err)
+	}
+	at := 0
+	if atMs > 0 {
+		at = p.FrameCountMs(atMs)
+	}
+	if at > p.FrameCount() {
+		at = p.FrameCount()
+	}
+	split := at * int(p.frame_width)
+	data := make([]byte, 0, len(*p.data)+len(*seg.data))
+	data = append(data, (*p.data)[:split]...)
+	data = append(data, *seg.data...)
+	data = append(data, (*p.data)[split:]...)
+	as := p.spawn(&data)
+	as.markers = p.moved_markers(at, seg.FrameCount())
+	if p.has_loop() {
+		frames := seg.FrameCount()
+		if as.loop_start >= at {
+			as.loop_start += frames
+		}
+		if as.loop_end > at {
+			as.loop_end += frames
+		}
+	}
+	return as
+}
+
+func (p *AudioSegment) prepend_data(head []byte) *AudioSegment {
+	data := make([]byte, 0, len(head)+len(*p.data))
+	data = append(data, head...)
+	data = append(data, *p.data...)
+	as := p.spawn(&data)
+	as.markers = p.moved_markers(0, len(head)/int(p.frame_width))
+	if p.has_loop() {
+		frames := len(head) / int(p.frame_width)
+		as.loop_start += frames
+		as.loop_end += frames
+	}
+	return as
 }
 
 func (p *AudioSegment) spawn(data *[]byte) *AudioSegment {
+	as := *p
+	as.set_data(data)
+	return &as
+}
+
+// set_data gives p data no other segment points into
+func (p *AudioSegment) set_data(data *[]byte) {
+	p.data = data
+	p.owner = &data_owner{}
+}
+
+// view is spawn for data pointing into the data of p. The view is counted on
+// the owner of the data, p itself is left untouched so concurrent views of
+// one segment don't race.
+func (p *AudioSegment) view(data *[]byte) *AudioSegment {
 	as := *p
 	as.data = data
+	if p.owner == nil {
+		// without an owner the view can't be recorded, it gets a copy
+		buf := make([]byte, len(*data))
+		copy(buf, *data)
+		as.set_data(&buf)
+		return &as
+	}
+	atomic.AddInt32(&p.owner.views, 1)
 	return &as
 }
 
+func (p *AudioSegment) silent_data(frames int) []byte {
+	data := make([]byte, frames*int(p.frame_width))
+	if p.sample_width == 1 {
+		// 8-bit wav samples are unsigned, silence sits at the midpoint
+		for i := range data {
+			data[i] = 0x80
+		}
+	}
+	return data
+}
+
+func (p *AudioSegment) check_compatible(seg *AudioSegment) error {
+	if p.channels != seg.channels || p.frame_rate != seg.frame_rate || p.sample_width != seg.sample_width {
+		return fmt.Errorf("Incompatible audio format (%d channels, %dHz, %d-bit), expected %d channels, %dHz, %d-bit",
+			seg.channels, seg.frame_rate, seg.sample_width*8, p.channels, p.frame_rate, p.sample_width*8)
+	}
+	return nil
+}
+
+// Validate checks the invariants the transforms rely on, useful on segments
+// built from external sample data: a known sample width, a frame width
+// matching it, a positive frame rate and data made of whole frames
+func (p *AudioSegment) Validate() error {
+	if p.sample_width < 1 || p.sample_width > 4 {
+		return errors.New(fmt_width_error(p.sample_width))
+	}
+	if p.channels == 0 {
+		return fmt.Errorf("AudioSegment has no channels")
+	}
+	if p.frame_width != p.channels*p.sample_width {
+		return fmt.Errorf("Frame width %d doesn't match %d channels of %d bytes", p.frame_width, p.channels, p.sample_width)
+	}
+	if p.frame_rate == 0 {
+		return fmt.Errorf("AudioSegment has no frame rate")
+	}
+	if p.data == nil {
+		return fmt.Errorf("AudioSegment has no data")
+	}
+	if len(*p.data)%int(p.frame_width) != 0 {
+		return fmt.Errorf("%d bytes of data don't make whole frames of %d bytes", len(*p.data), p.frame_width)
+	}
+	return nil
+}
+
 func (p *AudioSegment) Export(out_f string, format string) {
+	p.ExportWithOptions(out_f, format, ExportOptions{})
+}
+
+func (p *AudioSegment) ExportWithOptions(out_f string, format string, opts ExportOptions) {
+	if err := p.export(out_f, format, opts); err != nil {
+		panic(err)
+	}
+}
+
+// encode_wav applies the export options and returns the wav file bytes
+func (p *AudioSegment) encode_wav(opts ExportOptions) ([]byte, error) {
+	seg := p
+	if opts.FadeInMs > 0 {
+		seg = seg.FadeIn(opts.FadeInMs)
+	}
+	if opts.FadeOutMs > 0 {
+		seg = seg.FadeOut(opts.FadeOutMs)
+	}
+	if opts.DitherToWidth != 0 && opts.DitherToWidth != seg.sample_width {
+		if opts.DitherToWidth > seg.sample_width || opts.FloatOutput {
+			return nil, fmt.Errorf("Can't dither %d-bit audio to %d-bit", seg.sample_width*8, opts.DitherToWidth*8)
+		}
+		seg = seg.dither_to_width(opts.DitherToWidth, opts.DitherSeed)
+	}
+	var wav_data bytes.Buffer
+	if err := seg.saveWav(&wav_data, opts); err != nil {
+		return nil, err
+	}
+	return wav_data.Bytes(), nil
+}
+
+func (p *AudioSegment) export(out_f string, format string, opts ExportOptions) error {
+	wav_data, err := p.encode_wav(opts)
+	if err != nil {
+		return err
+	}
 	if format == "wav" {
-		fd, err := os.Create(out_f)
+		return ioutil.WriteFile(out_f, wav_data, 0644)
+	}
+
+	tmp, err := write_temp_wav(wav_data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return ffmpeg_encode(tmp, out_f, format, opts)
+}
+
+// frame rates tried by ExportWithinSize below the rate of the segment
+var size_budget_rates = []uint32{48000, 44100, 32000, 22050, 16000, 11025, 8000}
+
+// ExportWithinSize writes the wav of the highest frame rate, then the highest
+// sample width at that rate, that fits in maxBytes, never going above the
+// format of the segment or below 8kHz 8-bit. 44100Hz 8-bit is taken over
+// 11025Hz 16-bit. The channels are kept.
+func (p *AudioSegment) ExportWithinSize(out_f string, maxBytes int64) (err error) {
+	defer recover_clipped(&err)
+	type candidate struct {
+		rate  uint32
+		width uint16
+	}
+	rates := []uint32{p.frame_rate}
+	for _, rate := range size_budget_rates {
+		if rate < p.frame_rate {
+			rates = append(rates, rate)
+		}
+	}
+	// best quality first: highest rate, then highest width
+	candidates := make([]candidate, 0)
+	for _, rate := range rates {
+		for width := p.sample_width; width >= 1; width-- {
+			candidates = append(candidates, candidate{rate, width})
+		}
+	}
+
+	for _, c := range candidates {
+		frames := int64(math.Round(float64(p.FrameCount()) * float64(c.rate) / float64(p.frame_rate)))
+		// the riff, fmt and data headers take 44 bytes
+		if 44+frames*int64(p.channels)*int64(c.width) > maxBytes {
+			continue
+		}
+		seg := p
+		if c.width != seg.sample_width {
+			seg = seg.SetSampleWidth(c.width)
+		}
+		if c.rate != seg.frame_rate {
+			seg = seg.SetFrameRate(c.rate)
+		}
+		wav_data, err := seg.encode_wav(ExportOptions{})
 		if err != nil {
-			panic(err)
+			return err
+		}
+		if int64(len(wav_data)) > maxBytes {
+			continue
 		}
-		p.saveWav(fd)
+		return ioutil.WriteFile(out_f, wav_data, 0644)
+	}
+	return fmt.Errorf("%dms of audio doesn't fit in %d bytes even at 8kHz 8-bit", p.Len(), maxBytes)
+}
+
+// ExportPreview exports the segment played speed times faster, 2 for a quick
+// listen to a long file. It is a plain resample kept at the frame rate of the
+// segment, so the pitch moves with the speed (up an octave at 2) unlike
+// TimeStretch.
+func (p *AudioSegment) ExportPreview(out_f string, format string, speed float64) (err error) {
+	defer recover_clipped(&err)
+	if speed <= 0 || math.IsInf(speed, 0) || math.IsNaN(speed) {
+		return fmt.Errorf("Invalid preview speed %g", speed)
+	}
+	frames := int(math.Round(float64(p.FrameCount()) / speed))
+	return p.resample_frames(frames).export(out_f, format, ExportOptions{})
+}
+
+// ExportTo encodes the segment as format into w
+func (p *AudioSegment) ExportTo(w io.Writer, format string, opts ExportOptions) (err error) {
+	defer recover_clipped(&err)
+	wav_data, err := p.encode_wav(opts)
+	if err != nil {
+		return err
+	}
+	if format == "wav" {
+		_, err := w.Write(wav_data)
+		return err
 	}
+
+	tmp, err := write_temp_wav(wav_data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return ffmpeg_encode_to(tmp, w, format, opts)
 }
 
 func bytes2UInt(b []byte, order binary.ByteOrder) uint32 {
@@ -153,15 +783,25 @@ func extract_wav_headers(data *[]byte) []WavSubChunk {
 		subchunk_id := (*data)[pos : pos+4]
 		subchunk_size := bytes2UInt((*data)[pos+4:pos+8], binary.LittleEndian)
 		subchunks = append(subchunks, WavSubChunk{id: subchunk_id, position: pos, size: subchunk_size})
-		if bytes.Equal(subchunk_id, []byte{'d', 'a', 't', 'a'}) {
+		if bytes.Equal(subchunk_id, []byte{'d', 'a', 't', 'a'}) && unknown_data_size(subchunk_size) {
+			// streamed files don't know their data size, the audio runs to the end
+			break
+		}
+		// keep walking past data, LIST and cue chunks often follow it
+		next := uint64(pos) + uint64(subchunk_size) + 8 + uint64(subchunk_size%2)
+		if next > uint64(len(*data)) {
 			break
 		}
-		pos += subchunk_size + uint32(8)
+		pos = uint32(next)
 	}
 	return subchunks
 }
 
-func read_wav_data(data *[]byte) WavData {
+func unknown_data_size(size uint32) bool {
+	return size == 0 || size == 0xFFFFFFFF
+}
+
+func read_wav_data(data *[]byte) (WavData, error) {
 	headers := extract_wav_headers(data)
 	fmts := make([]WavSubChunk, 0, 2)
 	for i := 0; i < len(headers); i++ {
@@ -170,69 +810,457 @@ func read_wav_data(data *[]byte) WavData {
 		}
 	}
 	if len(fmts) == 0 || fmts[0].size < 16 {
-		panic("Couldn't find fmts header in wav data")
+		return WavData{}, fmt.Errorf("Couldn't find fmts header in wav data")
 	}
 	format := fmts[0]
 	pos := format.position + 8
 	audio_format := bytes2UShort((*data)[pos:pos+2], binary.LittleEndian)
-	if audio_format != 1 && audio_format != 0xFFFE {
-		errmsg := fmt.Sprintf("Unknown audio format 0x%X in wav data", audio_format)
-		panic(errmsg)
+	if audio_format == WavFormatExtensible && format.size >= 40 {
+		// the actual format is the start of the sub format guid
+		audio_format = bytes2UShort((*data)[pos+24:pos+26], binary.LittleEndian)
+	}
+	switch audio_format {
+	case WavFormatPCM, WavFormatFloat, WavFormatALaw, WavFormatMuLaw:
+	default:
+		return WavData{}, fmt.Errorf("Unknown audio format 0x%X in wav data", audio_format)
 	}
 	channels := bytes2UShort((*data)[pos+2:pos+4], binary.LittleEndian)
 	sample_rate := bytes2UInt((*data)[pos+4:pos+8], binary.LittleEndian)
 	bits_per_sample := bytes2UShort((*data)[pos+14:pos+16], binary.LittleEndian)
 
-	data_hdr := headers[len(headers)-1]
-	if !bytes.Equal(data_hdr.id, []byte{'d', 'a', 't', 'a'}) {
-		panic("Couldn't find data header in wav data")
+	var data_hdr *WavSubChunk
+	chunks := make([]WavChunk, 0)
+	for i := 0; i < len(headers); i++ {
+		if bytes.Equal(headers[i].id, []byte{'d', 'a', 't', 'a'}) {
+			if data_hdr == nil {
+				data_hdr = &headers[i]
+			}
+			continue
+		}
+		if bytes.Equal(headers[i].id, []byte{'f', 'm', 't', ' '}) {
+			continue
+		}
+		start := uint64(headers[i].position) + 8
+		end := start + uint64(headers[i].size)
+		if end > uint64(len(*data)) {
+			continue
+		}
+		chunk_data := make([]byte, end-start)
+		copy(chunk_data, (*data)[start:end])
+		chunks = append(chunks, WavChunk{id: headers[i].id, data: chunk_data})
+	}
+	if data_hdr == nil {
+		return WavData{}, fmt.Errorf("Couldn't find data header in wav data")
 	}
 	pos = data_hdr.position + 8
+	end := uint64(pos) + uint64(data_hdr.size)
+	if unknown_data_size(data_hdr.size) || end > uint64(len(*data)) {
+		end = uint64(len(*data))
+		if frame_width := uint64(channels) * uint64(bits_per_sample/8); frame_width > 0 {
+			end -= (end - uint64(pos)) % frame_width
+		}
+	}
 	return WavData{
 		audio_format:    audio_format,
 		channels:        channels,
 		sample_rate:     sample_rate,
 		bits_per_sample: bits_per_sample,
-		raw_data:        (*data)[pos : pos+data_hdr.size]}
+		raw_data:        (*data)[pos:end],
+		chunks:          chunks}, nil
 }
 
-func from_safe_wav(file string) *AudioSegment {
+func from_safe_wav(file string) (*AudioSegment, error) {
 	f, err := fd_or_tempfile(file, false)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	defer f.Close()
 	f.Seek(0, 0)
-	obj := new_audio_segment_with_wav(f)
-	f.Close()
-	return obj
+	return new_audio_segment_with_wav(f)
 }
 
-func From_file(file string, format string) *AudioSegment {
+func from_file(file string, format string) (*AudioSegment, error) {
 	if format == "wav" {
 		return from_safe_wav(file)
 	}
-	return nil
+	if !supported_formats[format] {
+		return nil, fmt.Errorf("Unsupported audio format %q", format)
+	}
+	return ffmpeg_decode(file, format)
+}
+
+// DurationOf returns the length of file without decoding it, from the header
+// for wav and through ffprobe for other formats
+func DurationOf(file string, format string) (time.Duration, error) {
+	if format != "wav" {
+		if !supported_formats[format] {
+			return 0, fmt.Errorf("Unsupported audio format %q", format)
+		}
+		return ffprobe_duration(file)
+	}
+	f, err := fd_or_tempfile(file, false)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	byte_rate, _, size, err := wav_data_extent(f)
+	if err != nil {
+		return 0, err
+	}
+	if byte_rate == 0 {
+		return 0, fmt.Errorf("Couldn't find the fmt and data headers of %s", file)
+	}
+	return time.Duration(float64(size) / float64(byte_rate) * float64(time.Second)), nil
 }
 
-func new_audio_segment_with_wav(file *os.File) *AudioSegment {
-	data, err := ioutil.ReadAll(file)
+func From_file(file string, format string) *AudioSegment {
+	obj, err := from_file(file, format)
 	if err != nil {
 		panic(err)
 	}
+	return obj
+}
+
+// FromFileChannels loads at most channels channels of file. Wav files keep
+// their first channels, other formats with more channels are downmixed by
+// ffmpeg, found through ffprobe; files with fewer are never upmixed.
+func FromFileChannels(file string, format string, channels uint16) (*AudioSegment, error) {
+	if channels == 0 {
+		return nil, fmt.Errorf("Channel count must be positive")
+	}
+	if format != "wav" {
+		source, err := ffprobe_channels(file)
+		if err != nil {
+			return nil, err
+		}
+		var args []string
+		if source > channels {
+			args = []string{"-ac", strconv.Itoa(int(channels))}
+		}
+		return ffmpeg_decode(file, format, args...)
+	}
+	obj, err := from_safe_wav(file)
+	if err != nil {
+		return nil, err
+	}
+	if channels >= obj.channels {
+		return obj, nil
+	}
+	return obj.select_channels(channels), nil
+}
+
+// SetChannels averages the channels down to mono or copies a mono channel to
+// every output channel
+func (p *AudioSegment) SetChannels(channels uint16) *AudioSegment {
+	if channels == p.channels {
+		return p.spawn_channel_floats(p.channel_floats())
+	}
+	var out [][]float64
+	if channels == 1 {
+		out = [][]float64{p.mono_frames(0, p.FrameCount())}
+	} else if p.channels == 1 && channels > 1 {
+		mono := p.channel_floats()[0]
+		for c := 0; c < int(channels); c++ {
+			out = append(out, mono)
+		}
+	} else {
+		errmsg := fmt.Sprintf("Can't convert %d channels to %d channels", p.channels, channels)
+		panic(errmsg)
+	}
+	as := p.spawn_channel_floats(out)
+	as.channels = channels
+	as.frame_width = channels * p.sample_width
+	return as
+}
+
+// DownmixTo mixes the channels through a matrix with one row per output
+// channel and one weight per input channel, e.g. BS.775 coefficients to fold
+// 5.1 down to stereo
+func (p *AudioSegment) DownmixTo(channels uint16, coeffs [][]float64) *AudioSegment {
+	if channels == 0 || len(coeffs) != int(channels) {
+		errmsg := fmt.Sprintf("Downmix matrix has %d rows for %d output channels", len(coeffs), channels)
+		panic(errmsg)
+	}
+	for i, row := range coeffs {
+		if len(row) != int(p.channels) {
+			errmsg := fmt.Sprintf("Downmix matrix row %d has %d weights for %d input channels", i, len(row), p.channels)
+			panic(errmsg)
+		}
+	}
+	in := p.channel_floats()
+	frames := p.FrameCount()
+	out := make([][]float64, channels)
+	for o, row := range coeffs {
+		mixed := make([]float64, frames)
+		for c, weight := range row {
+			if weight == 0 {
+				continue
+			}
+			for i, v := range in[c] {
+				mixed[i] += v * weight
+			}
+		}
+		out[o] = mixed
+	}
+	as := p.spawn_channel_floats(out)
+	as.channels = channels
+	as.frame_width = channels * p.sample_width
+	return as
+}
+
+// SetSampleWidth converts the samples to sampleWidth bytes, keeping their
+// level relative to full scale
+func (p *AudioSegment) SetSampleWidth(sampleWidth uint16) *AudioSegment {
+	if sampleWidth < 1 || sampleWidth > 4 {
+		panic(fmt_width_error(sampleWidth))
+	}
+	as := p.spawn(p.data)
+	as.sample_width = sampleWidth
+	as.frame_width = p.channels * sampleWidth
+	scale := as.max_possible_amplitude() / p.max_possible_amplitude()
+	samples := p.samples()
+	for i, v := range samples {
+		samples[i] = as.clamp_float(float64(v) * scale)
+	}
+	return as.spawn_samples(samples)
+}
+
+func (p *AudioSegment) SplitToMono() []*AudioSegment {
+	frames := p.FrameCount()
+	width := int(p.sample_width)
+	monos := make([]*AudioSegment, p.channels)
+	for c := range monos {
+		data := make([]byte, frames*width)
+		for i := 0; i < frames; i++ {
+			offset := i*int(p.frame_width) + c*width
+			copy(data[i*width:(i+1)*width], (*p.data)[offset:offset+width])
+		}
+		mono := p.spawn(&data)
+		mono.channels = 1
+		mono.frame_width = p.sample_width
+		monos[c] = mono
+	}
+	return monos
+}
+
+// ApplyToChannel runs fn on channel alone, as a mono segment, and puts the
+// result back in its place. fn must keep the format and the length.
+func (p *AudioSegment) ApplyToChannel(channel int, fn func(*AudioSegment) *AudioSegment) *AudioSegment {
+	if channel < 0 || channel >= int(p.channels) {
+		errmsg := fmt.Sprintf("Channel %d out of range for %d channels", channel, p.channels)
+		panic(errmsg)
+	}
+	monos := p.SplitToMono()
+	mono := monos[channel]
+	out := fn(mono)
+	if err := mono.check_compatible(out); err != nil {
+		panic(err)
+	}
+	if out.FrameCount() != mono.FrameCount() {
+		errmsg := fmt.Sprintf("Channel transform changed the length from %d to %d frames", mono.FrameCount(), out.FrameCount())
+		panic(errmsg)
+	}
+	width := int(p.sample_width)
+	data := make([]byte, len(*p.data))
+	copy(data, *p.data)
+	for i := 0; i < p.FrameCount(); i++ {
+		offset := i*int(p.frame_width) + channel*width
+		copy(data[offset:offset+width], (*out.data)[i*width:(i+1)*width])
+	}
+	return p.spawn(&data)
+}
+
+// SplitN cuts the segment into n pieces of equal frame counts, the last one
+// taking the remaining frames. Appending the pieces gives back the segment.
+func (p *AudioSegment) SplitN(n int) []*AudioSegment {
+	if n < 1 {
+		errmsg := fmt.Sprintf("Can't split into %d pieces", n)
+		panic(errmsg)
+	}
+	frame_width := int(p.frame_width)
+	size := p.FrameCount() / n * frame_width
+	pieces := make([]*AudioSegment, n)
+	for i := range pieces {
+		end := (i + 1) * size
+		if i == n-1 {
+			end = p.FrameCount() * frame_width
+		}
+		data := (*p.data)[i*size : end]
+		pieces[i] = p.slice_loop(p.view(&data), i*size/frame_width, end/frame_width)
+	}
+	return pieces
+}
+
+// StreamBytes hands the raw audio data to fn in windows of windowBytes rounded
+// down to whole frames, the last one possibly shorter, and stops at the first
+// error fn returns. The windows point into the segment and must not be
+// modified or kept after fn returns.
+func (p *AudioSegment) StreamBytes(windowBytes int, fn func([]byte) error) error {
+	frame_width := int(p.frame_width)
+	size := windowBytes - windowBytes%frame_width
+	if size <= 0 {
+		return fmt.Errorf("Window of %d bytes is smaller than a %d byte frame", windowBytes, frame_width)
+	}
+	data := *p.data
+	for start := 0; start < len(data); start += size {
+		end := start + size
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := fn(data[start:end:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *AudioSegment) select_channels(channels uint16) *AudioSegment {
+	frames := p.FrameCount()
+	keep := int(channels * p.sample_width)
+	data := make([]byte, frames*keep)
+	for i := 0; i < frames; i++ {
+		copy(data[i*keep:(i+1)*keep], (*p.data)[i*int(p.frame_width):])
+	}
+	as := p.spawn(&data)
+	as.channels = channels
+	as.frame_width = channels * p.sample_width
+	return as
+}
+
+// MaxDecodeDurationMs caps the duration of the wav files loaded, checked from
+// the header before the audio is read. 0 means no limit.
+var MaxDecodeDurationMs int
+
+// TruncateLongDecodes loads the first MaxDecodeDurationMs of longer files
+// instead of failing
+var TruncateLongDecodes bool
+
+// wav_data_extent walks the chunk headers of file and returns the byte rate
+// from the fmt chunk with the position and size of the audio in the data
+// chunk. byte_rate is 0 when either chunk is missing.
+func wav_data_extent(file *os.File) (byte_rate, data_pos, data_size int64, err error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	file_size := info.Size()
+	header := make([]byte, 16)
+	for pos := int64(12); pos+8 <= file_size; {
+		if _, err := file.ReadAt(header[:8], pos); err != nil {
+			return 0, 0, 0, err
+		}
+		size := int64(bytes2UInt(header[4:8], binary.LittleEndian))
+		switch string(header[0:4]) {
+		case "fmt ":
+			if _, err := file.ReadAt(header, pos+8); err != nil {
+				return 0, 0, 0, err
+			}
+			byte_rate = int64(bytes2UInt(header[8:12], binary.LittleEndian))
+		case "data":
+			if byte_rate == 0 {
+				return 0, 0, 0, nil
+			}
+			if unknown_data_size(uint32(size)) || pos+8+size > file_size {
+				size = file_size - pos - 8
+			}
+			return byte_rate, pos + 8, size, nil
+		}
+		pos += 8 + size + size%2
+	}
+	return 0, 0, 0, nil
+}
+
+// wav_read_limit returns how many bytes of file may be read under
+// MaxDecodeDurationMs, or -1 for the whole file
+func wav_read_limit(file *os.File) (int64, error) {
+	if MaxDecodeDurationMs <= 0 {
+		return -1, nil
+	}
+	byte_rate, pos, size, err := wav_data_extent(file)
+	if err != nil {
+		return 0, err
+	}
+	if byte_rate == 0 {
+		// let the parser report malformed files
+		return -1, nil
+	}
+	limit := int64(MaxDecodeDurationMs) * byte_rate / 1000
+	if size <= limit {
+		return -1, nil
+	}
+	if !TruncateLongDecodes {
+		return 0, fmt.Errorf("Wav audio is longer than %dms (%dms)", MaxDecodeDurationMs, size*1000/byte_rate)
+	}
+	return pos + limit, nil
+}
+
+func new_audio_segment_with_wav(file *os.File) (*AudioSegment, error) {
+	limit, err := wav_read_limit(file)
+	if err != nil {
+		return nil, err
+	}
+	var reader io.Reader = file
+	if limit >= 0 {
+		reader = io.LimitReader(file, limit)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
 	obj := AudioSegment{}
-	wav_data := read_wav_data(&data)
+	wav_data, err := read_wav_data(&data)
+	if err != nil {
+		return nil, err
+	}
 	obj.channels = wav_data.channels
 	obj.sample_width = wav_data.bits_per_sample / 8
 	obj.frame_rate = wav_data.sample_rate
 	obj.frame_width = obj.channels * obj.sample_width
-	obj.data = &wav_data.raw_data
-
-	if obj.sample_width == 3 {
-		// needs to be converted from 21-bit to 32-bit
-		panic("sample cannot be 24-bit")
+	obj.set_data(&wav_data.raw_data)
+	switch wav_data.audio_format {
+	case WavFormatFloat:
+		if err := obj.convert_float_data(); err != nil {
+			return nil, err
+		}
+	case WavFormatALaw, WavFormatMuLaw:
+		if err := obj.expand_g711(wav_data.audio_format); err != nil {
+			return nil, err
+		}
 	}
+	obj.chunks = wav_data.chunks
+	obj.markers = obj.wav_markers()
+	if loops := smpl_loops(obj.find_chunk("smpl")); len(loops) > 0 && int(loops[0][1]) <= obj.FrameCount() {
+		obj.loop_start, obj.loop_end = int(loops[0][0]), int(loops[0][1])
+	}
+
+	return &obj, nil
+}
 
-	return &obj
+// convert_float_data turns 32 or 64-bit float samples into 32-bit integers
+func (p *AudioSegment) convert_float_data() error {
+	width := int(p.sample_width)
+	if width != 4 && width != 8 {
+		return fmt.Errorf("Unsupported float sample width %d bytes", width)
+	}
+	src := *p.data
+	count := len(src) / width
+	data := make([]byte, count*4)
+	p.sample_width = 4
+	p.frame_width = p.channels * 4
+	scale := p.max_possible_amplitude()
+	for i := 0; i < count; i++ {
+		var v float64
+		if width == 4 {
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(src[i*4:])))
+		} else {
+			v = math.Float64frombits(binary.LittleEndian.Uint64(src[i*8:]))
+		}
+		// float files may go over full scale, always clamp those
+		v = math.Max(float64(p.min_sample()), math.Min(math.Round(v*scale), float64(p.max_sample())))
+		encode_sample(data[i*4:], 4, int32(v))
+	}
+	p.set_data(&data)
+	return nil
 }
 
 func NewAudioSegment() *AudioSegment {
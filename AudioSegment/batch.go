@@ -0,0 +1,139 @@
+package AudioSegment
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// BatchError collects the files a batch operation failed on
+type BatchError struct {
+	Errors []FileError
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d files failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func process_file(in_f, out_f, format string, fn func(*AudioSegment) *AudioSegment) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r == ErrClipped {
+				err = ErrClipped
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	seg, err := from_file(in_f, format_from_path(in_f))
+	if err != nil {
+		return err
+	}
+	return fn(seg).export(out_f, format, ExportOptions{})
+}
+
+// ProcessDir loads every supported audio file of inDir, applies fn and writes
+// the result to outDir as format, using workers goroutines. Failing files are
+// skipped and reported together in a *BatchError, as are files whose output
+// name is taken by another file of the same name, e.g. a.mp3 after a.flac.
+func ProcessDir(inDir, outDir, format string, workers int, fn func(*AudioSegment) *AudioSegment) error {
+	entries, err := ioutil.ReadDir(inDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	type job struct{ in_f, out_f string }
+	files := make(chan job)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	batch_err := &BatchError{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range files {
+				if err := process_file(file.in_f, file.out_f, format, fn); err != nil {
+					lock.Lock()
+					batch_err.Errors = append(batch_err.Errors, FileError{Path: file.in_f, Err: err})
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	outputs := make(map[string]string)
+	for _, entry := range entries {
+		ext := format_from_path(entry.Name())
+		if entry.IsDir() || (ext != "wav" && !supported_formats[ext]) {
+			continue
+		}
+		in_f := filepath.Join(inDir, entry.Name())
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		out_f := filepath.Join(outDir, name+"."+format)
+		// a.wav and a.mp3 would both be written to a.<format>
+		if first, ok := outputs[out_f]; ok {
+			lock.Lock()
+			batch_err.Errors = append(batch_err.Errors, FileError{Path: in_f,
+				Err: fmt.Errorf("Output %s is already written for %s", out_f, first)})
+			lock.Unlock()
+			continue
+		}
+		outputs[out_f] = in_f
+		files <- job{in_f, out_f}
+	}
+	close(files)
+	wg.Wait()
+
+	if len(batch_err.Errors) > 0 {
+		sort.Slice(batch_err.Errors, func(i, j int) bool {
+			return batch_err.Errors[i].Path < batch_err.Errors[j].Path
+		})
+		return batch_err
+	}
+	return nil
+}
+
+// ExportChannels writes every channel to basePath_<n>.<format> and returns the
+// paths. A mono segment is an error unless allowMono is set, in which case the
+// single channel is written. Already written files are removed on failure.
+func (p *AudioSegment) ExportChannels(basePath, format string, allowMono bool) (paths []string, err error) {
+	defer recover_clipped(&err)
+	if p.channels == 1 && !allowMono {
+		return nil, fmt.Errorf("AudioSegment is mono, there are no channels to split")
+	}
+	paths = make([]string, 0, p.channels)
+	for c, mono := range p.SplitToMono() {
+		out_f := fmt.Sprintf("%s_%d.%s", basePath, c, format)
+		if err := mono.export(out_f, format, ExportOptions{}); err != nil {
+			os.Remove(out_f)
+			for _, path := range paths {
+				os.Remove(path)
+			}
+			return nil, err
+		}
+		paths = append(paths, out_f)
+	}
+	return paths, nil
+}
@@ -0,0 +1,41 @@
+package AudioSegment
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProcessDirOutputCollision(t *testing.T) {
+	in, out := t.TempDir(), t.TempDir()
+	seg := SineWave(440, 50, 8000, 2)
+	seg.Export(filepath.Join(in, "a.wav"), "wav")
+	seg.Export(filepath.Join(in, "b.wav"), "wav")
+	if err := ioutil.WriteFile(filepath.Join(in, "a.aiff"), []byte("not audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ProcessDir(in, out, "wav", 2, func(s *AudioSegment) *AudioSegment { return s })
+	var batch_err *BatchError
+	if !errors.As(err, &batch_err) {
+		t.Fatalf("expected a *BatchError, got %v", err)
+	}
+	collided := false
+	for _, file_err := range batch_err.Errors {
+		if filepath.Base(file_err.Path) == "b.wav" {
+			t.Errorf("b.wav failed: %v", file_err.Err)
+		}
+		if filepath.Base(file_err.Path) == "a.wav" && strings.Contains(file_err.Err.Error(), "a.aiff") {
+			collided = true
+		}
+	}
+	if !collided {
+		t.Errorf("no collision reported for a.wav: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "b.wav")); err != nil {
+		t.Errorf("b.wav wasn't written: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package AudioSegment
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	onset_hop_ms = 5
+	min_bpm      = 60
+	max_bpm      = 200
+)
+
+// onset_strength is how much the level rises in every onset_hop_ms window
+func (p *AudioSegment) onset_strength() []float64 {
+	envelope := p.Envelope(onset_hop_ms, RMSDetector)
+	onsets := make([]float64, len(envelope))
+	for i := 1; i < len(envelope); i++ {
+		onsets[i] = math.Max(0, envelope[i]-envelope[i-1])
+	}
+	return onsets
+}
+
+// beat_period returns the beat period in onset windows, autocorrelating the
+// onsets over the lags of min_bpm to max_bpm, or 0 without onsets
+func beat_period(onsets []float64) float64 {
+	min_lag := int(60000 / max_bpm / onset_hop_ms)
+	max_lag := int(math.Ceil(60000 / min_bpm / onset_hop_ms))
+	if max_lag >= len(onsets) {
+		max_lag = len(onsets) - 1
+	}
+	corr := make([]float64, max_lag+2)
+	for lag := min_lag - 1; lag <= max_lag+1 && lag < len(onsets); lag++ {
+		for i := lag; i < len(onsets); i++ {
+			corr[lag] += onsets[i] * onsets[i-lag]
+		}
+	}
+	best := 0
+	for lag := min_lag; lag <= max_lag; lag++ {
+		if corr[lag] > corr[best] {
+			best = lag
+		}
+	}
+	if best == 0 || corr[best] == 0 {
+		return 0
+	}
+	// parabolic interpolation between the neighbouring lags
+	a, b, c := corr[best-1], corr[best], corr[best+1]
+	if denom := a - 2*b + c; denom != 0 {
+		return float64(best) + 0.5*(a-c)/denom
+	}
+	return float64(best)
+}
+
+// EstimateBPM estimates the tempo between 60 and 200 BPM from the
+// periodicity of the onsets, 0 when the segment has none
+func (p *AudioSegment) EstimateBPM() float64 {
+	period := beat_period(p.onset_strength())
+	if period == 0 {
+		return 0
+	}
+	return 60000 / (period * onset_hop_ms)
+}
+
+// BeatPositions returns the beats in ms: a grid at the estimated tempo, at the
+// phase collecting the most onset strength, with every beat moved to the
+// strongest onset within a tenth of a beat
+func (p *AudioSegment) BeatPositions() []int {
+	onsets := p.onset_strength()
+	period := beat_period(onsets)
+	beats := make([]int, 0)
+	if period == 0 {
+		return beats
+	}
+
+	best_phase, best_sum := 0, -1.0
+	for phase := 0; float64(phase) < period; phase++ {
+		var sum float64
+		for t := float64(phase); int(t) < len(onsets); t += period {
+			sum += onsets[int(t)]
+		}
+		if sum > best_sum {
+			best_phase, best_sum = phase, sum
+		}
+	}
+
+	window := int(period / 10)
+	for t := float64(best_phase); int(math.Round(t)) < len(onsets); t += period {
+		center := int(math.Round(t))
+		peak := center
+		for i := center - window; i <= center+window; i++ {
+			if i >= 0 && i < len(onsets) && onsets[i] > onsets[peak] {
+				peak = i
+			}
+		}
+		beats = append(beats, peak*onset_hop_ms)
+	}
+	return beats
+}
+
+// grid_frames is the length in frames of the whole number of grid units of
+// subdivision per beat at bpm closest to the segment, at least one unit
+func (p *AudioSegment) grid_frames(bpm float64, subdivision int) int {
+	if bpm <= 0 || subdivision < 1 {
+		errmsg := fmt.Sprintf("Invalid grid of %g bpm divided by %d", bpm, subdivision)
+		panic(errmsg)
+	}
+	unit := 60 * float64(p.frame_rate) / bpm / float64(subdivision)
+	units := math.Max(1, math.Round(float64(p.FrameCount())/unit))
+	return int(math.Round(units * unit))
+}
+
+// GridAdjustment is the number of frames SnapToGrid pads, or trims when
+// negative, for the same grid
+func (p *AudioSegment) GridAdjustment(bpm float64, subdivision int) int {
+	return p.grid_frames(bpm, subdivision) - p.FrameCount()
+}
+
+// SnapToGrid pads the segment with silence or trims its end so it lasts a
+// whole number of grid units, subdivision per beat at bpm (4 for 16th notes),
+// and tiles seamlessly when looped. GridAdjustment reports which way it goes.
+func (p *AudioSegment) SnapToGrid(bpm float64, subdivision int) *AudioSegment {
+	frames := p.grid_frames(bpm, subdivision)
+	if frames <= p.FrameCount() {
+		return p.SliceFrames(0, frames)
+	}
+	data := make([]byte, 0, frames*int(p.frame_width))
+	data = append(data, *p.data...)
+	data = append(data, p.silent_data(frames-p.FrameCount())...)
+	return p.spawn(&data)
+}
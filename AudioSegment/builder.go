@@ -0,0 +1,131 @@
+package AudioSegment
+
+import (
+	"fmt"
+)
+
+type builder_item struct {
+	path       string
+	seg        *AudioSegment
+	silence_ms int
+}
+
+func (item builder_item) name() string {
+	if item.path != "" {
+		return item.path
+	}
+	return "segment"
+}
+
+// Builder collects files, segments and silences and joins them in a single
+// pass when Build is called. Files are only decoded by Build, which then
+// converts every audio item to the highest frame rate, channel count and
+// sample width among them like ConcatAll. The first failure is returned by
+// Build with the index of its item.
+type Builder struct {
+	items []builder_item
+	err   error
+}
+
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) Add(path string) *Builder {
+	index := len(b.items)
+	b.items = append(b.items, builder_item{path: path})
+	if b.err == nil && path == "" {
+		b.err = fmt.Errorf("Builder item %d: empty path", index)
+	}
+	return b
+}
+
+func (b *Builder) AddSegment(seg *AudioSegment) *Builder {
+	index := len(b.items)
+	b.items = append(b.items, builder_item{seg: seg})
+	if b.err == nil && (seg == nil || seg.data == nil) {
+		b.err = fmt.Errorf("Builder item %d: empty AudioSegment", index)
+	}
+	return b
+}
+
+func (b *Builder) AddSilence(ms int) *Builder {
+	index := len(b.items)
+	b.items = append(b.items, builder_item{silence_ms: ms})
+	if b.err == nil && ms < 0 {
+		b.err = fmt.Errorf("Builder item %d: negative silence duration (%dms)", index, ms)
+	}
+	return b
+}
+
+// segments decodes the files and converts the audio items to their common
+// format, silences are left nil
+func (b *Builder) segments() ([]*AudioSegment, error) {
+	segs := make([]*AudioSegment, len(b.items))
+	audio := make([]*AudioSegment, 0, len(b.items))
+	for i, item := range b.items {
+		seg := item.seg
+		if item.path != "" {
+			var err error
+			if seg, err = from_file(item.path, format_from_path(item.path)); err != nil {
+				return nil, fmt.Errorf("Builder item %d (%s): %v", i, item.path, err)
+			}
+		}
+		if seg != nil {
+			segs[i] = seg
+			audio = append(audio, seg)
+		}
+	}
+	if len(audio) == 0 {
+		return nil, fmt.Errorf("Builder has no audio to take the format from")
+	}
+	frame_rate, channels, sample_width := common_format(audio)
+	for i, seg := range segs {
+		if seg == nil {
+			continue
+		}
+		var err error
+		if segs[i], err = seg.convert_format(frame_rate, channels, sample_width); err != nil {
+			return nil, fmt.Errorf("Builder item %d (%s): %v", i, b.items[i].name(), err)
+		}
+	}
+	return segs, nil
+}
+
+func (b *Builder) Build() (*AudioSegment, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	segs, err := b.segments()
+	if err != nil {
+		return nil, err
+	}
+	// the result carries the metadata of the first audio item
+	var first *AudioSegment
+	for _, seg := range segs {
+		if seg != nil {
+			first = seg
+			break
+		}
+	}
+
+	frame_width := int(first.frame_width)
+	size := 0
+	for i, seg := range segs {
+		if seg != nil {
+			size += len(*seg.data)
+		} else {
+			size += first.FrameCountMs(b.items[i].silence_ms) * frame_width
+		}
+	}
+
+	data := make([]byte, 0, size)
+	for i, seg := range segs {
+		if seg != nil {
+			data = append(data, *seg.data...)
+			continue
+		}
+		data = append(data, first.silent_data(first.FrameCountMs(b.items[i].silence_ms))...)
+	}
+	return first.spawn(&data), nil
+}
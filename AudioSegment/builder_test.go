@@ -0,0 +1,63 @@
+package AudioSegment
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	dir := t.TempDir()
+	mono := SineWave(440, 100, 8000, 1)
+	stereo := SineWave(440, 100, 16000, 2).SetChannels(2)
+	three := FromFloatSamples(make([]float64, 300), 3, 16000, 2)
+	// written after Add, Build decodes it
+	late := filepath.Join(dir, "late.wav")
+	cases := []struct {
+		name  string
+		build func(b *Builder) *Builder
+		// error substring, or the expected format
+		err                    string
+		frame_rate             uint32
+		channels, sample_width uint16
+		frames                 int
+	}{
+		{"converts to the common format", func(b *Builder) *Builder {
+			return b.AddSegment(mono).AddSilence(50).AddSegment(stereo)
+		}, "", 16000, 2, 2, 1600 + 800 + 1600},
+		{"decodes files in Build", func(b *Builder) *Builder {
+			b.AddSegment(stereo).Add(late)
+			stereo.Export(late, "wav")
+			return b
+		}, "", 16000, 2, 2, 3200},
+		{"reports the incompatible item", func(b *Builder) *Builder {
+			return b.AddSegment(three).AddSilence(10).AddSegment(stereo)
+		}, "Builder item 2 (segment)", 0, 0, 0, 0},
+		{"reports the missing file", func(b *Builder) *Builder {
+			return b.AddSegment(mono).Add(filepath.Join(dir, "missing.wav"))
+		}, "Builder item 1", 0, 0, 0, 0},
+		{"needs audio", func(b *Builder) *Builder {
+			return b.AddSilence(10)
+		}, "no audio", 0, 0, 0, 0},
+	}
+	for _, c := range cases {
+		out, err := c.build(NewBuilder()).Build()
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: error %v, want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if out.frame_rate != c.frame_rate || out.channels != c.channels || out.sample_width != c.sample_width {
+			t.Errorf("%s: %dHz, %d channels, %d bytes, want %dHz, %d channels, %d bytes", c.name,
+				out.frame_rate, out.channels, out.sample_width, c.frame_rate, c.channels, c.sample_width)
+		}
+		if out.FrameCount() != c.frames {
+			t.Errorf("%s: %d frames, want %d", c.name, out.FrameCount(), c.frames)
+		}
+	}
+}
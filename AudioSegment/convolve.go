@@ -0,0 +1,126 @@
+package AudioSegment
+
+import (
+	"fmt"
+	"math"
+)
+
+// kernels longer than this are applied through the FFT
+const direct_convolution_taps = 64
+
+// convolve_floats returns the first len(x) samples of x convolved with kernel
+func convolve_floats(x, kernel []float64) []float64 {
+	out := make([]float64, len(x))
+	if len(kernel) <= direct_convolution_taps {
+		for i := range out {
+			var sum float64
+			for k, tap := range kernel {
+				if i-k < 0 {
+					break
+				}
+				sum += x[i-k] * tap
+			}
+			out[i] = sum
+		}
+		return out
+	}
+
+	// overlap-add: every block of x is convolved with the kernel through the
+	// FFT and added in at its position
+	n := next_pow2(2 * len(kernel))
+	block := n - len(kernel) + 1
+	spectrum := make([]complex128, n)
+	for i, tap := range kernel {
+		spectrum[i] = complex(tap, 0)
+	}
+	fft(spectrum)
+	buf := make([]complex128, n)
+	for start := 0; start < len(x); start += block {
+		for i := range buf {
+			buf[i] = 0
+		}
+		for i := 0; i < block && start+i < len(x); i++ {
+			buf[i] = complex(x[start+i], 0)
+		}
+		fft(buf)
+		for i := range buf {
+			buf[i] *= spectrum[i]
+		}
+		ifft(buf)
+		for i := 0; i < n && start+i < len(out); i++ {
+			out[start+i] += real(buf[i])
+		}
+	}
+	return out
+}
+
+// Convolve filters every channel with the FIR kernel, or impulse response, as
+// given: no gain compensation is applied and results over full scale are
+// clipped. The length is unchanged, the tail past the end is dropped.
+func (p *AudioSegment) Convolve(kernel []float64) *AudioSegment {
+	if len(kernel) == 0 {
+		panic("Empty convolution kernel")
+	}
+	channels := p.channel_floats()
+	for c, samples := range channels {
+		channels[c] = convolve_floats(samples, kernel)
+	}
+	return p.spawn_channel_floats(channels)
+}
+
+// ApplyImpulseResponse convolves the segment with the impulse response in the
+// irPath audio file and blends the result in by mix (0 dry, 1 fully wet). The
+// response is resampled to the rate of the segment and scaled to unit energy.
+// A response with the channel count of the segment is applied per channel,
+// any other is mixed down to mono and applied to every channel. Responses that
+// fail to decode, or are empty or silent, return an error.
+func (p *AudioSegment) ApplyImpulseResponse(irPath string, mix float64) (*AudioSegment, error) {
+	if mix < 0 || mix > 1 {
+		errmsg := fmt.Sprintf("Invalid impulse response mix %g", mix)
+		panic(errmsg)
+	}
+	ir, err := from_file(irPath, format_from_path(irPath))
+	if err != nil {
+		return nil, fmt.Errorf("Impulse response %s: %v", irPath, err)
+	}
+	if ir.frame_rate != p.frame_rate {
+		ir = ir.SetFrameRate(p.frame_rate)
+	}
+	var kernels [][]float64
+	if ir.channels == p.channels {
+		kernels = ir.channel_floats()
+	} else {
+		kernels = [][]float64{ir.mono_frames(0, ir.FrameCount())}
+	}
+	if len(kernels[0]) == 0 {
+		return nil, fmt.Errorf("Impulse response %s is empty", irPath)
+	}
+
+	// one scale for every channel keeps the balance of stereo responses
+	var energy float64
+	for _, kernel := range kernels {
+		var sum float64
+		for _, v := range kernel {
+			sum += v * v
+		}
+		energy = math.Max(energy, sum)
+	}
+	if energy == 0 {
+		return nil, fmt.Errorf("Impulse response %s is silent", irPath)
+	}
+	scale := 1 / math.Sqrt(energy)
+
+	channels := p.channel_floats()
+	for c, dry := range channels {
+		kernel := kernels[0]
+		if len(kernels) > 1 {
+			kernel = kernels[c]
+		}
+		wet := convolve_floats(dry, kernel)
+		for i := range wet {
+			wet[i] = (1-mix)*dry[i] + mix*wet[i]*scale
+		}
+		channels[c] = wet
+	}
+	return p.spawn_channel_floats(channels), nil
+}
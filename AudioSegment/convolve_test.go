@@ -0,0 +1,41 @@
+package AudioSegment
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyImpulseResponse(t *testing.T) {
+	dir := t.TempDir()
+	impulse := filepath.Join(dir, "impulse.wav")
+	FromFloatSamples([]float64{0.5, 0, 0, 0}, 1, 8000, 2).Export(impulse, "wav")
+	silent := filepath.Join(dir, "silent.wav")
+	Silent(10, 8000).Export(silent, "wav")
+	seg := SineWave(440, 100, 8000, 2).ApplyGain(-6)
+	cases := []struct {
+		name string
+		path string
+		err  string
+	}{
+		{"unit impulse", impulse, ""},
+		{"missing file", filepath.Join(dir, "missing.wav"), "missing.wav"},
+		{"silent response", silent, "is silent"},
+	}
+	for _, c := range cases {
+		out, err := seg.ApplyImpulseResponse(c.path, 1)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: error %v, want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		// a single scaled impulse passes the audio through
+		if !out.ApproxEqual(seg, 0.001) {
+			t.Errorf("%s: the convolved audio differs from the input", c.name)
+		}
+	}
+}
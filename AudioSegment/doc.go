@@ -0,0 +1,27 @@
+// Package AudioSegment loads, edits and exports PCM audio.
+//
+// Transforms leave the segment they are called on as it is, they return a new
+// *AudioSegment and panic on invalid arguments, so they chain:
+//
+//	seg.Normalize(0.1).FadeIn(50).FadeOut(200).SetFrameRate(44100)
+//
+// Slices and other views share the data of their segment instead of copying
+// it. The in-place variants, ApplyGainInPlace and MapInPlace, are the only
+// methods that change their segment; they copy data shared with a view first,
+// so the views never see the change.
+//
+// The chainable transforms are ApplyGain, ApplyGainRatio, ApplyGainRange,
+// Normalize, NormalizeAWeighted, Fade, FadeIn, FadeOut, Slice, Append, Overlay,
+// OverlayRange, OverlayAt, SetFrameRate, SetChannels, DownmixTo,
+// SetSampleWidth, Reinterpret, SetLoopPoints, LoopN, TimeStretch, PitchShift,
+// Reverb, Echo, NoiseGate, Tremolo, DeEss, DeClick, PeakingEQ, LowShelf,
+// HighShelf, BitCrush, SampleRateReduce, Convolve, CrossfadeJoin, Prepend,
+// PrependSilence, SliceFrames, OverlayAtFrame, Limit, RemoveDCOffset,
+// SliceClean, ApplyToChannel, StripSilence, Insert, SnapToGrid, SidechainDuck,
+// ApplyRecipe and Map.
+//
+// Operations that can fail on valid arguments, such as decoding, exporting,
+// ApplyImpulseResponse, which decodes its response file, or AppendCrossfade
+// between segments of different formats, return an error instead and are not
+// part of the chain.
+package AudioSegment
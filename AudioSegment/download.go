@@ -0,0 +1,68 @@
+package AudioSegment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// HTTPClient is used by FromURL to download audio
+var HTTPClient = http.DefaultClient
+
+// MaxDownloadBytes caps the size of the files FromURL downloads, 0 means no
+// limit. MaxDecodeDurationMs applies as well once the file is decoded.
+var MaxDownloadBytes int64
+
+func FromURL(rawurl string, format string) (*AudioSegment, error) {
+	return FromURLContext(context.Background(), rawurl, format)
+}
+
+// FromURLContext downloads rawurl to a temporary file and decodes it. An empty
+// format is taken from the extension of the url path.
+func FromURLContext(ctx context.Context, rawurl string, format string) (*AudioSegment, error) {
+	if format == "" {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
+		format = format_from_path(u.Path)
+	}
+
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Downloading %s failed: %s", rawurl, resp.Status)
+	}
+	if MaxDownloadBytes > 0 && resp.ContentLength > MaxDownloadBytes {
+		return nil, fmt.Errorf("Download of %d bytes exceeds the %d bytes limit", resp.ContentLength, MaxDownloadBytes)
+	}
+
+	tmp, err := fd_or_tempfile("", true)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	var body io.Reader = resp.Body
+	if MaxDownloadBytes > 0 {
+		body = io.LimitReader(resp.Body, MaxDownloadBytes+1)
+	}
+	n, err := io.Copy(tmp, body)
+	tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+	if MaxDownloadBytes > 0 && n > MaxDownloadBytes {
+		return nil, fmt.Errorf("Download exceeds the %d bytes limit", MaxDownloadBytes)
+	}
+	return from_file(tmp.Name(), format)
+}
@@ -0,0 +1,548 @@
+package AudioSegment
+
+import (
+	"fmt"
+	"math"
+)
+
+func db_to_ratio(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+func (p *AudioSegment) ApplyGain(db float64) *AudioSegment {
+	return p.apply_ratio(db_to_ratio(db))
+}
+
+// ApplyGainRatio scales the samples by a linear, non-negative factor
+func (p *AudioSegment) ApplyGainRatio(ratio float64) *AudioSegment {
+	if ratio < 0 {
+		errmsg := fmt.Sprintf("Invalid negative gain ratio %g", ratio)
+		panic(errmsg)
+	}
+	return p.apply_ratio(ratio)
+}
+
+const gain_range_ramp_ms = 5
+
+// ApplyGainRange applies db between startMs and endMs only, clamped to the
+// segment. The gain ramps in and out over gain_range_ramp_ms inside the range
+// so the level doesn't step.
+func (p *AudioSegment) ApplyGainRange(db float64, startMs, endMs int) *AudioSegment {
+	if endMs < startMs {
+		errmsg := fmt.Sprintf("Invalid gain range %dms-%dms", startMs, endMs)
+		panic(errmsg)
+	}
+	frames := p.FrameCount()
+	clamp := func(ms int) int {
+		if ms < 0 {
+			return 0
+		}
+		if frame := p.FrameCountMs(ms); frame < frames {
+			return frame
+		}
+		return frames
+	}
+	start, end := clamp(startMs), clamp(endMs)
+	ramp := p.FrameCountMs(gain_range_ramp_ms)
+	if ramp > (end-start)/2 {
+		ramp = (end - start) / 2
+	}
+
+	ratio := db_to_ratio(db)
+	channels := int(p.channels)
+	samples := p.samples()
+	for i := start * channels; i < end*channels; i++ {
+		frame := i / channels
+		gain := ratio
+		if edge := frame - start; edge < ramp {
+			gain = 1 + (ratio-1)*float64(edge+1)/float64(ramp+1)
+		} else if edge := end - 1 - frame; edge < ramp {
+			gain = 1 + (ratio-1)*float64(edge+1)/float64(ramp+1)
+		}
+		samples[i] = p.saturate_float(float64(samples[i]) * gain)
+	}
+	return p.spawn_samples(samples)
+}
+
+// Normalize applies the gain that brings the peak sample to headroom dB below
+// full scale. Silent segments are returned unchanged.
+func (p *AudioSegment) Normalize(headroom float64) *AudioSegment {
+	peak := p.peak_frames(0, p.FrameCount())
+	if peak == 0 {
+		return p.apply_ratio(1)
+	}
+	return p.ApplyGain(-headroom - ratio_to_db(peak/p.max_possible_amplitude()))
+}
+
+// AlbumNormalize measures the level of segs as one album, weighting every
+// track by its length, and applies the single gain that brings it to targetDB
+// dBFS to all of them, keeping the balance between tracks. The gain is returned
+// with the tracks; an album of silence gets 0.
+func AlbumNormalize(segs []*AudioSegment, targetDB float64) ([]*AudioSegment, float64) {
+	var energy float64
+	var samples int
+	for _, seg := range segs {
+		count := len(*seg.data) / int(seg.sample_width)
+		level := seg.RMS() / seg.max_possible_amplitude()
+		energy += level * level * float64(count)
+		samples += count
+	}
+	gain := 0.0
+	if energy > 0 {
+		gain = targetDB - ratio_to_db(math.Sqrt(energy/float64(samples)))
+	}
+	out := make([]*AudioSegment, len(segs))
+	for i, seg := range segs {
+		out[i] = seg.ApplyGain(gain)
+	}
+	return out, gain
+}
+
+// ApplyGainInPlace is ApplyGain modifying the segment itself, see MapInPlace
+// for how shared data is handled
+func (p *AudioSegment) ApplyGainInPlace(db float64) *AudioSegment {
+	ratio := db_to_ratio(db)
+	return p.MapInPlace(func(sample int32, channel int) int32 {
+		return p.saturate_float(float64(sample) * ratio)
+	})
+}
+
+func (p *AudioSegment) apply_ratio(ratio float64) *AudioSegment {
+	samples := p.samples()
+	for i, v := range samples {
+		samples[i] = p.saturate_float(float64(v) * ratio)
+	}
+	return p.spawn_samples(samples)
+}
+
+type comb_filter struct {
+	buffer   []float64
+	pos      int
+	feedback float64
+}
+
+func (f *comb_filter) process(x float64) float64 {
+	y := f.buffer[f.pos]
+	f.buffer[f.pos] = x + y*f.feedback
+	f.pos = (f.pos + 1) % len(f.buffer)
+	return y
+}
+
+type allpass_filter struct {
+	buffer []float64
+	pos    int
+	gain   float64
+}
+
+func (f *allpass_filter) process(x float64) float64 {
+	delayed := f.buffer[f.pos]
+	v := x + delayed*f.gain
+	f.buffer[f.pos] = v
+	f.pos = (f.pos + 1) % len(f.buffer)
+	return delayed - v*f.gain
+}
+
+// Reverb is a Schroeder reverberator: four parallel combs scaled to roomMs
+// followed by two allpass diffusers. decay is the time in seconds for the
+// tail to fall by 60dB, mix the 0..1 share of the wet signal.
+func (p *AudioSegment) Reverb(roomMs int, decay float64, mix float64) *AudioSegment {
+	if roomMs <= 0 || decay <= 0 || mix < 0 || mix > 1 {
+		errmsg := fmt.Sprintf("Invalid reverb parameters (room %dms, decay %gs, mix %g)", roomMs, decay, mix)
+		panic(errmsg)
+	}
+	rate := float64(p.frame_rate)
+	delay_frames := func(ms float64) int {
+		frames := int(ms * rate / 1000)
+		if frames < 1 {
+			frames = 1
+		}
+		return frames
+	}
+
+	channels := p.channel_floats()
+	for c, samples := range channels {
+		combs := make([]*comb_filter, 0, 4)
+		for _, scale := range []float64{1, 1.137, 1.265, 1.409} {
+			delay := float64(roomMs) * scale
+			combs = append(combs, &comb_filter{
+				buffer:   make([]float64, delay_frames(delay)),
+				feedback: math.Pow(10, -3*delay/1000/decay),
+			})
+		}
+		allpasses := []*allpass_filter{
+			{buffer: make([]float64, delay_frames(5)), gain: 0.7},
+			{buffer: make([]float64, delay_frames(1.7)), gain: 0.7},
+		}
+
+		out := make([]float64, len(samples))
+		for i, x := range samples {
+			var wet float64
+			for _, comb := range combs {
+				wet += comb.process(x)
+			}
+			wet /= float64(len(combs))
+			for _, allpass := range allpasses {
+				wet = allpass.process(wet)
+			}
+			out[i] = (1-mix)*x + mix*wet
+		}
+		channels[c] = out
+	}
+	return p.spawn_channel_floats(channels)
+}
+
+// Echo overlays repeats copies of the segment, each delayed by a further
+// delayMs and attenuated by a further factor of decay. The length is unchanged.
+func (p *AudioSegment) Echo(delayMs int, decay float64, repeats int) *AudioSegment {
+	if delayMs <= 0 || decay < 0 || repeats < 0 {
+		errmsg := fmt.Sprintf("Invalid echo parameters (delay %dms, decay %g, repeats %d)", delayMs, decay, repeats)
+		panic(errmsg)
+	}
+	out := p.ApplyGain(0)
+	delay := p.FrameCountMs(delayMs)
+	for k := 1; k <= repeats && k*delay < p.FrameCount(); k++ {
+		echo := p.ApplyGain(ratio_to_db(math.Pow(decay, float64(k))))
+		out = out.overlay_frames(echo, k*delay, 0, p.FrameCount())
+	}
+	return out
+}
+
+// smoothing_coefficient is the one-pole coefficient reaching ~63% of a step
+// after ms milliseconds
+func (p *AudioSegment) smoothing_coefficient(ms float64) float64 {
+	frames := ms * float64(p.frame_rate) / 1000
+	if frames < 1 {
+		return 0
+	}
+	return math.Exp(-1 / frames)
+}
+
+// NoiseGate silences each channel while its envelope stays under thresholdDB.
+// The gate opens over attackMs, stays open holdMs after the signal falls under
+// the threshold and then closes over releaseMs. The timeline is unchanged.
+func (p *AudioSegment) NoiseGate(thresholdDB float64, attackMs, holdMs, releaseMs float64) *AudioSegment {
+	if attackMs < 0 || holdMs < 0 || releaseMs < 0 {
+		errmsg := fmt.Sprintf("Invalid noise gate timing (attack %gms, hold %gms, release %gms)", attackMs, holdMs, releaseMs)
+		panic(errmsg)
+	}
+	threshold := db_to_ratio(thresholdDB) * p.max_possible_amplitude()
+	attack := p.smoothing_coefficient(attackMs)
+	release := p.smoothing_coefficient(releaseMs)
+	// the detector follows peaks instantly and decays over 10ms
+	detector := p.smoothing_coefficient(10)
+	hold_frames := int(holdMs * float64(p.frame_rate) / 1000)
+
+	channels := p.channel_floats()
+	for c, samples := range channels {
+		var envelope float64
+		gain := 0.0
+		held := hold_frames + 1
+		for i, x := range samples {
+			level := math.Abs(x)
+			if level > envelope {
+				envelope = level
+			} else {
+				envelope = level + detector*(envelope-level)
+			}
+
+			if envelope >= threshold {
+				held = 0
+			} else {
+				held++
+			}
+			if held <= hold_frames {
+				gain = 1 + attack*(gain-1)
+			} else {
+				gain = release * gain
+			}
+			samples[i] = x * gain
+		}
+		channels[c] = samples
+	}
+	return p.spawn_channel_floats(channels)
+}
+
+// SidechainDuck lowers the segment while key, e.g. a voiceover, is louder than
+// thresholdDB: the key level above the threshold is reduced by ratio, the gain
+// falling over attackMs and recovering over releaseMs. key is resampled to the
+// frame rate of the segment and stops ducking where it ends.
+func (p *AudioSegment) SidechainDuck(key *AudioSegment, thresholdDB, ratio, attackMs, releaseMs float64) *AudioSegment {
+	if ratio < 1 || attackMs < 0 || releaseMs < 0 {
+		errmsg := fmt.Sprintf("Invalid ducking settings (ratio %g, attack %gms, release %gms)", ratio, attackMs, releaseMs)
+		panic(errmsg)
+	}
+	if key.frame_rate != p.frame_rate {
+		key = key.SetFrameRate(p.frame_rate)
+	}
+	attack := p.smoothing_coefficient(attackMs)
+	release := p.smoothing_coefficient(releaseMs)
+	detector := p.smoothing_coefficient(10)
+	scale := key.max_possible_amplitude()
+	key_channels := key.channel_floats()
+
+	channels := p.channel_floats()
+	frames := p.FrameCount()
+	var envelope, reduction float64
+	for i := 0; i < frames; i++ {
+		var level float64
+		if i < key.FrameCount() {
+			for _, samples := range key_channels {
+				level = math.Max(level, math.Abs(samples[i])/scale)
+			}
+		}
+		if level > envelope {
+			envelope = level
+		} else {
+			envelope = level + detector*(envelope-level)
+		}
+
+		target := 0.0
+		if over := ratio_to_db(envelope) - thresholdDB; over > 0 {
+			target = over * (1 - 1/ratio)
+		}
+		if target > reduction {
+			reduction = target + attack*(reduction-target)
+		} else {
+			reduction = target + release*(reduction-target)
+		}
+		gain := db_to_ratio(-reduction)
+		for c := range channels {
+			channels[c][i] *= gain
+		}
+	}
+	return p.spawn_channel_floats(channels)
+}
+
+// Tremolo modulates the amplitude with a rateHz oscillator. depth 0 leaves the
+// audio untouched, depth 1 swings the gain between 1 and 0.
+func (p *AudioSegment) Tremolo(rateHz float64, depth float64) *AudioSegment {
+	if rateHz <= 0 || depth < 0 || depth > 1 {
+		errmsg := fmt.Sprintf("Invalid tremolo parameters (rate %gHz, depth %g)", rateHz, depth)
+		panic(errmsg)
+	}
+	channels := p.channel_floats()
+	step := 2 * math.Pi * rateHz / float64(p.frame_rate)
+	for c, samples := range channels {
+		for i, x := range samples {
+			gain := 1 - depth*0.5*(1-math.Cos(step*float64(i)))
+			samples[i] = x * gain
+		}
+		channels[c] = samples
+	}
+	return p.spawn_channel_floats(channels)
+}
+
+// BitCrush quantizes the samples down to bits of resolution, keeping the
+// width of the segment
+func (p *AudioSegment) BitCrush(bits uint16) *AudioSegment {
+	if bits < 1 || bits > p.sample_width*8 {
+		errmsg := fmt.Sprintf("Invalid bit depth %d for %d-bit audio", bits, p.sample_width*8)
+		panic(errmsg)
+	}
+	shift := p.sample_width*8 - bits
+	return p.Map(func(sample int32, channel int) int32 {
+		return sample >> shift << shift
+	})
+}
+
+// SampleRateReduce holds every factor-th frame over the following ones,
+// without filtering, for an aliased lo-fi sound at the same frame rate
+func (p *AudioSegment) SampleRateReduce(factor int) *AudioSegment {
+	if factor < 1 {
+		errmsg := fmt.Sprintf("Invalid sample rate reduction factor %d", factor)
+		panic(errmsg)
+	}
+	channels := int(p.channels)
+	samples := p.samples()
+	for i := range samples {
+		frame := i / channels
+		samples[i] = samples[(frame-frame%factor)*channels+i%channels]
+	}
+	return p.spawn_samples(samples)
+}
+
+const (
+	declick_window_ms = 20
+	// longer discontinuities are left alone as real transients
+	declick_max_ms = 2
+	// residual over local residual RMS flagged at sensitivity 1
+	declick_ratio = 4
+)
+
+// DeClick finds samples that jump away from the line through their neighbours
+// much further than the surrounding audio does and redraws them as a straight
+// line between the intact samples on either side. Higher sensitivity catches
+// smaller clicks, 1 suits most material.
+func (p *AudioSegment) DeClick(sensitivity float64) *AudioSegment {
+	if sensitivity <= 0 {
+		errmsg := fmt.Sprintf("Invalid declick sensitivity %g", sensitivity)
+		panic(errmsg)
+	}
+	ratio := declick_ratio / sensitivity
+	half_window := p.FrameCountMs(declick_window_ms) / 2
+	max_run := p.FrameCountMs(declick_max_ms)
+	if half_window < 2 {
+		half_window = 2
+	}
+	if max_run < 1 {
+		max_run = 1
+	}
+
+	channels := p.channel_floats()
+	for c, x := range channels {
+		n := len(x)
+		if n < 3 {
+			continue
+		}
+		// distance of every sample from the line through its neighbours,
+		// extrapolated at the ends
+		residual := make([]float64, n)
+		for i := 1; i < n-1; i++ {
+			residual[i] = x[i] - (x[i-1]+x[i+1])/2
+		}
+		residual[0] = (x[0] - 2*x[1] + x[2]) / 2
+		residual[n-1] = (x[n-1] - 2*x[n-2] + x[n-3]) / 2
+
+		squares := make([]float64, n+1)
+		for i, r := range residual {
+			squares[i+1] = squares[i] + r*r
+		}
+		clicked := make([]bool, n)
+		for i, r := range residual {
+			lo, hi := i-half_window, i+half_window+1
+			if lo < 0 {
+				lo = 0
+			}
+			if hi > n {
+				hi = n
+			}
+			local := math.Sqrt((squares[hi] - squares[lo]) / float64(hi-lo))
+			clicked[i] = local > 0 && math.Abs(r) > ratio*local
+		}
+
+		out := make([]float64, n)
+		copy(out, x)
+		for start := 0; start < n; start++ {
+			if !clicked[start] {
+				continue
+			}
+			end := start
+			for end+1 < n && clicked[end+1] {
+				end++
+			}
+			if end-start+1 <= max_run {
+				switch {
+				case start == 0 && end == n-1:
+				case start == 0:
+					for i := start; i <= end; i++ {
+						out[i] = x[end+1]
+					}
+				case end == n-1:
+					for i := start; i <= end; i++ {
+						out[i] = x[start-1]
+					}
+				default:
+					a, b := x[start-1], x[end+1]
+					span := float64(end - start + 2)
+					for i := start; i <= end; i++ {
+						out[i] = a + (b-a)*float64(i-start+1)/span
+					}
+				}
+			}
+			start = end
+		}
+		channels[c] = out
+	}
+	return p.spawn_channel_floats(channels)
+}
+
+// RemoveDCOffset subtracts the mean of every channel from its samples
+func (p *AudioSegment) RemoveDCOffset() *AudioSegment {
+	channels := p.channel_floats()
+	for c, samples := range channels {
+		var sum float64
+		for _, v := range samples {
+			sum += v
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		mean := sum / float64(len(samples))
+		for i := range samples {
+			samples[i] -= mean
+		}
+		channels[c] = samples
+	}
+	return p.spawn_channel_floats(channels)
+}
+
+const slice_clean_ramp_ms = 2
+
+// SliceClean cuts startMs-endMs without clicks at the cut points: the DC
+// offset of the piece is removed so it starts and ends around zero, and both
+// ends ramp over slice_clean_ramp_ms
+func (p *AudioSegment) SliceClean(startMs, endMs int) *AudioSegment {
+	start, end := p.FrameCountMs(startMs), p.FrameCountMs(endMs)
+	if end > p.FrameCount() {
+		end = p.FrameCount()
+	}
+	if startMs < 0 || end < start {
+		errmsg := fmt.Sprintf("Invalid slice %dms-%dms", startMs, endMs)
+		panic(errmsg)
+	}
+	piece := p.SliceFrames(start, end).RemoveDCOffset()
+	ramp := piece.FrameCountMs(slice_clean_ramp_ms)
+	if ramp > piece.FrameCount()/2 {
+		ramp = piece.FrameCount() / 2
+	}
+	frames := piece.FrameCount()
+	return piece.fade_frames(0, ramp, Linear).fade_frames(frames-ramp, frames, reversed(Linear))
+}
+
+const limiter_lookahead_ms = 1.5
+
+// Limit keeps every sample under ceilingDB: the gain ramps down over
+// limiter_lookahead_ms ahead of each peak, so it is already reduced when the
+// peak arrives, and recovers over releaseMs. All channels share the gain.
+func (p *AudioSegment) Limit(ceilingDB float64, releaseMs float64) *AudioSegment {
+	if ceilingDB > 0 || releaseMs < 0 {
+		errmsg := fmt.Sprintf("Invalid limiter parameters (ceiling %gdB, release %gms)", ceilingDB, releaseMs)
+		panic(errmsg)
+	}
+	ceiling := db_to_ratio(ceilingDB) * p.max_possible_amplitude()
+	release := p.smoothing_coefficient(releaseMs)
+	lookahead := int(limiter_lookahead_ms * float64(p.frame_rate) / 1000)
+	if lookahead < 1 {
+		lookahead = 1
+	}
+
+	channels := p.channel_floats()
+	frames := p.FrameCount()
+	// the gain each frame needs on its own
+	needed := make([]float64, frames)
+	for i := range needed {
+		needed[i] = 1
+		for _, samples := range channels {
+			if level := math.Abs(samples[i]); level > ceiling {
+				needed[i] = math.Min(needed[i], ceiling/level)
+			}
+		}
+	}
+
+	gain := 1.0
+	for i := 0; i < frames; i++ {
+		// head for the lowest of the ramps down to the coming peaks
+		target := 1.0
+		for j := i; j < frames && j <= i+lookahead; j++ {
+			if needed[j] < 1 {
+				ramp := needed[j] + (1-needed[j])*float64(j-i)/float64(lookahead)
+				target = math.Min(target, ramp)
+			}
+		}
+		gain = math.Min(target, 1-release*(1-gain))
+		for _, samples := range channels {
+			samples[i] *= gain
+		}
+	}
+	return p.spawn_channel_floats(channels)
+}
@@ -0,0 +1,104 @@
+package AudioSegment
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLimitKeepsPeaksUnderCeiling(t *testing.T) {
+	cases := []struct {
+		name      string
+		seg       *AudioSegment
+		ceilingDB float64
+	}{
+		{"16-bit mono", SineWave(440, 200, 44100, 2), -6},
+		{"16-bit stereo", SineWave(440, 200, 44100, 2).SetChannels(2), -1},
+		{"8-bit", SineWave(440, 200, 8000, 1), -3},
+		{"32-bit", SineWave(440, 200, 48000, 4), -12},
+	}
+	for _, c := range cases {
+		// a full scale burst followed by a quiet tone the limiter must recover for
+		quiet := SineWave(440, 500, c.seg.frame_rate, c.seg.sample_width).ApplyGain(-30)
+		if c.seg.channels == 2 {
+			quiet = quiet.SetChannels(2)
+		}
+		seg := c.seg.Append(quiet)
+		out := seg.Limit(c.ceilingDB, 50)
+		// rounding to the sample width may go one step over
+		ceiling := db_to_ratio(c.ceilingDB)*out.max_possible_amplitude() + 1
+		if peak := out.peak_frames(0, out.FrameCount()); peak > ceiling {
+			t.Errorf("%s: peak is %.0f, over the %gdB ceiling %.0f", c.name, peak, c.ceilingDB, ceiling)
+		}
+		tail := out.FrameCount() - out.FrameCountMs(100)
+		before, after := seg.dbfs_frames(tail, seg.FrameCount()), out.dbfs_frames(tail, out.FrameCount())
+		if math.Abs(before-after) > 0.1 {
+			t.Errorf("%s: quiet tail went from %.2fdB to %.2fdB after the release", c.name, before, after)
+		}
+	}
+}
+
+func TestEchoOfAnImpulse(t *testing.T) {
+	cases := []struct {
+		delayMs, repeats int
+		decay            float64
+		// impulses that fit in the 1s segment
+		want int
+	}{
+		{100, 3, 0.5, 4},
+		{250, 2, 0.8, 3},
+		{400, 5, 0.5, 3},
+		{100, 0, 0.5, 1},
+	}
+	for _, c := range cases {
+		values := make([]float64, 8000)
+		values[0] = 0.5
+		seg := FromFloatSamples(values, 1, 8000, 2)
+		out := seg.Echo(c.delayMs, c.decay, c.repeats)
+		if out.FrameCount() != seg.FrameCount() {
+			t.Errorf("delay %dms: %d frames, want %d", c.delayMs, out.FrameCount(), seg.FrameCount())
+		}
+		want := make([]int32, seg.FrameCount())
+		for k := 0; k < c.want; k++ {
+			want[k*seg.FrameCountMs(c.delayMs)] = int32(math.Round(16384 * math.Pow(c.decay, float64(k))))
+		}
+		for i, v := range out.samples() {
+			if d := v - want[i]; d > 1 || d < -1 {
+				t.Errorf("delay %dms, decay %g: sample %d is %d, want %d", c.delayMs, c.decay, i, v, want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestTremoloEnvelope(t *testing.T) {
+	cases := []struct {
+		rateHz, depth float64
+	}{
+		{5, 0.5},
+		{2, 1},
+		{10, 0},
+	}
+	for _, c := range cases {
+		values := make([]float64, 8000)
+		for i := range values {
+			values[i] = 0.5
+		}
+		out := FromFloatSamples(values, 1, 8000, 2).Tremolo(c.rateHz, c.depth)
+		samples := out.GetFloatSamples()
+		lowest, highest := 1.0, 0.0
+		for i, v := range samples {
+			gain := v / 0.5
+			lowest, highest = math.Min(lowest, gain), math.Max(highest, gain)
+			// the gain starts at 1 and comes back to it every 1/rateHz
+			phase := float64(i) * c.rateHz / 8000
+			want := 1 - c.depth*0.5*(1-math.Cos(2*math.Pi*phase))
+			if math.Abs(gain-want) > 0.001 {
+				t.Errorf("%gHz, depth %g: gain %.4f at frame %d, want %.4f", c.rateHz, c.depth, gain, i, want)
+				break
+			}
+		}
+		if math.Abs(lowest-(1-c.depth)) > 0.001 || math.Abs(highest-1) > 0.001 {
+			t.Errorf("%gHz, depth %g: gain swings %.3f-%.3f, want %.3f-1", c.rateHz, c.depth, lowest, highest, 1-c.depth)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package AudioSegment
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Exporter writes a wav file incrementally. The header is written with the
+// format of the first segment and its sizes are patched on Close.
+type Exporter struct {
+	file      *os.File
+	w         *bufio.Writer
+	format    *AudioSegment
+	data_pos  int64
+	data_size int64
+	closed    bool
+}
+
+func NewExporter(path string) (*Exporter, error) {
+	fd, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{file: fd, w: bufio.NewWriter(fd)}, nil
+}
+
+func (e *Exporter) write_header(seg *AudioSegment) error {
+	fmt_chunk, err := seg.fmt_chunk(binary.LittleEndian, WavFormatPCM, false)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 12)
+	copy(header[0:4], "RIFF")
+	copy(header[8:12], "WAVE")
+	if _, err := e.w.Write(header); err != nil {
+		return err
+	}
+	if err := write_wav_chunk(e.w, binary.LittleEndian, []byte{'f', 'm', 't', ' '}, fmt_chunk); err != nil {
+		return err
+	}
+	// the data size is unknown until Close
+	if _, err := e.w.Write([]byte{'d', 'a', 't', 'a', 0, 0, 0, 0}); err != nil {
+		return err
+	}
+	e.data_pos = int64(12 + 8 + len(fmt_chunk) + 8)
+	return nil
+}
+
+func (e *Exporter) WriteSegment(seg *AudioSegment) error {
+	if e.closed {
+		return fmt.Errorf("Exporter is closed")
+	}
+	if e.format == nil {
+		if err := e.write_header(seg); err != nil {
+			return err
+		}
+		e.format = seg
+	} else if err := e.format.check_compatible(seg); err != nil {
+		return err
+	}
+	if e.data_pos+e.data_size+int64(len(*seg.data)) > math.MaxUint32 {
+		return fmt.Errorf("Wav data would exceed 4GB")
+	}
+	n, err := e.w.Write(*seg.data)
+	e.data_size += int64(n)
+	return err
+}
+
+func (e *Exporter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if e.format == nil {
+		e.file.Close()
+		return fmt.Errorf("Exporter closed before any AudioSegment was written")
+	}
+	if err := e.finish(); err != nil {
+		e.file.Close()
+		return err
+	}
+	return e.file.Close()
+}
+
+// finish pads the data chunk to an even size and writes the final sizes
+func (e *Exporter) finish() error {
+	if e.data_size%2 == 1 {
+		if _, err := e.w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	if err := e.w.Flush(); err != nil {
+		return err
+	}
+	return e.patch_sizes()
+}
+
+func (e *Exporter) patch_sizes() error {
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(e.data_pos-8+e.data_size+e.data_size%2))
+	if _, err := e.file.WriteAt(size, 4); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(size, uint32(e.data_size))
+	_, err := e.file.WriteAt(size, e.data_pos-4)
+	return err
+}
+
+// Abort stops the export at any point, e.g. when a recording is cancelled,
+// and leaves a playable wav of the whole frames that reached the file. A file
+// nothing was written to is removed. Abort after Close does nothing.
+func (e *Exporter) Abort() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if e.format == nil {
+		e.file.Close()
+		return os.Remove(e.file.Name())
+	}
+	if err := e.truncate(); err != nil {
+		e.file.Close()
+		return err
+	}
+	return e.file.Close()
+}
+
+// truncate cuts the file after the last whole frame that reached it and
+// writes the sizes of what is left
+func (e *Exporter) truncate() error {
+	// a failed write may have left part of the data behind in the buffer
+	e.w.Flush()
+	info, err := e.file.Stat()
+	if err != nil {
+		return err
+	}
+	if written := info.Size() - e.data_pos; written < e.data_size {
+		e.data_size = written
+	}
+	e.data_size -= e.data_size % int64(e.format.frame_width)
+	end := e.data_pos + e.data_size
+	if e.data_size%2 == 1 {
+		if _, err := e.file.WriteAt([]byte{0}, end); err != nil {
+			return err
+		}
+		end++
+	}
+	if err := e.file.Truncate(end); err != nil {
+		return err
+	}
+	return e.patch_sizes()
+}
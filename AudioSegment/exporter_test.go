@@ -0,0 +1,101 @@
+package AudioSegment
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExporterClose(t *testing.T) {
+	seg := SineWave(440, 100, 8000, 1)
+	cases := []struct {
+		name     string
+		segments []*AudioSegment
+		fails    bool
+	}{
+		{"nothing written", nil, true},
+		{"one segment", []*AudioSegment{seg}, false},
+		{"odd data size", []*AudioSegment{seg, seg.SliceFrames(0, 1)}, false},
+	}
+	for _, c := range cases {
+		file := filepath.Join(t.TempDir(), "out.wav")
+		e, err := NewExporter(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []byte{}
+		for _, s := range c.segments {
+			if err := e.WriteSegment(s); err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+			want = append(want, *s.data...)
+		}
+		if err := e.Close(); (err != nil) != c.fails {
+			t.Fatalf("%s: Close returned %v", c.name, err)
+		}
+		// the file is closed once, a second Close has nothing left to do
+		if err := e.file.Close(); err == nil {
+			t.Errorf("%s: the file was still open after Close", c.name)
+		}
+		if err := e.Close(); err != nil {
+			t.Errorf("%s: second Close returned %v", c.name, err)
+		}
+		if c.fails {
+			continue
+		}
+		out, err := from_file(file, "wav")
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.Equal(*out.data, want) {
+			t.Errorf("%s: read back %d bytes, want %d", c.name, len(*out.data), len(want))
+		}
+		if info, _ := os.Stat(file); info.Size()%2 != 0 {
+			t.Errorf("%s: file size %d is odd", c.name, info.Size())
+		}
+	}
+}
+
+func TestExporterAbort(t *testing.T) {
+	seg := SineWave(440, 100, 8000, 2)
+	cases := []struct {
+		name     string
+		segments []*AudioSegment
+	}{
+		{"nothing written", nil},
+		{"one segment", []*AudioSegment{seg}},
+	}
+	for _, c := range cases {
+		file := filepath.Join(t.TempDir(), "out.wav")
+		e, err := NewExporter(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range c.segments {
+			if err := e.WriteSegment(s); err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+		}
+		if err := e.Abort(); err != nil {
+			t.Fatalf("%s: Abort returned %v", c.name, err)
+		}
+		if err := e.file.Close(); err == nil {
+			t.Errorf("%s: the file was still open after Abort", c.name)
+		}
+		_, err = os.Stat(file)
+		if len(c.segments) == 0 {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s: the empty file wasn't removed", c.name)
+			}
+			continue
+		}
+		out, err := from_file(file, "wav")
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if out.FrameCount() != seg.FrameCount() {
+			t.Errorf("%s: %d frames, want %d", c.name, out.FrameCount(), seg.FrameCount())
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package AudioSegment
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	converter  = "ffmpeg"
+	prober     = "ffprobe"
+	tools_lock sync.Mutex
+	// result of the last FFmpegAvailable probe, nil until it runs
+	ffmpeg_probe *ffmpeg_probe_result
+)
+
+type ffmpeg_probe_result struct {
+	ok      bool
+	version string
+}
+
+// SetFFmpegPath sets the ffmpeg executable, a bare name is looked up in PATH
+func SetFFmpegPath(path string) {
+	tools_lock.Lock()
+	defer tools_lock.Unlock()
+	converter = path
+	ffmpeg_probe = nil
+}
+
+// SetFFprobePath sets the ffprobe executable, a bare name is looked up in PATH
+func SetFFprobePath(path string) {
+	tools_lock.Lock()
+	defer tools_lock.Unlock()
+	prober = path
+}
+
+func converter_path() string {
+	tools_lock.Lock()
+	defer tools_lock.Unlock()
+	return converter
+}
+
+func prober_path() string {
+	tools_lock.Lock()
+	defer tools_lock.Unlock()
+	return prober
+}
+
+// FFmpegAvailable runs ffmpeg -version and reports whether it worked along
+// with the version, or the error when it didn't. The result is cached until
+// the path changes.
+func FFmpegAvailable() (bool, string) {
+	tools_lock.Lock()
+	defer tools_lock.Unlock()
+	if ffmpeg_probe == nil {
+		ffmpeg_probe = &ffmpeg_probe_result{}
+		out, err := exec.Command(converter, "-version").Output()
+		if err != nil {
+			ffmpeg_probe.version = err.Error()
+		} else {
+			line := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+			fields := strings.Fields(line)
+			ffmpeg_probe.ok = true
+			ffmpeg_probe.version = line
+			if len(fields) >= 3 && fields[1] == "version" {
+				ffmpeg_probe.version = fields[2]
+			}
+		}
+	}
+	return ffmpeg_probe.ok, ffmpeg_probe.version
+}
+
+// formats decoded through ffmpeg, wav is read natively
+var supported_formats = map[string]bool{
+	"aac":  true,
+	"aiff": true,
+	"flac": true,
+	"m4a":  true,
+	"mp3":  true,
+	"ogg":  true,
+	"opus": true,
+	"wma":  true,
+}
+
+// ffmpeg names the containers of some formats differently from their file
+// extension, the others share it
+var (
+	ffmpeg_demuxers = map[string]string{"m4a": "mov", "opus": "ogg", "wma": "asf"}
+	ffmpeg_muxers   = map[string]string{"aac": "adts", "m4a": "ipod", "opus": "ogg", "wma": "asf"}
+)
+
+// container returns the ffmpeg demuxer or muxer in names for format
+func container(names map[string]string, format string) string {
+	if name, ok := names[format]; ok {
+		return name
+	}
+	return format
+}
+
+func format_from_path(path string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+}
+
+// ffmpeg_slots bounds the ffmpeg processes running at once, nil means no bound
+var (
+	ffmpeg_slots      = make(chan struct{}, runtime.NumCPU())
+	ffmpeg_slots_lock sync.Mutex
+)
+
+// SetMaxFFmpegWorkers limits how many ffmpeg processes may run concurrently,
+// n <= 0 removes the limit. It defaults to the number of CPUs.
+func SetMaxFFmpegWorkers(n int) {
+	ffmpeg_slots_lock.Lock()
+	defer ffmpeg_slots_lock.Unlock()
+	if n <= 0 {
+		ffmpeg_slots = nil
+	} else {
+		ffmpeg_slots = make(chan struct{}, n)
+	}
+}
+
+func acquire_ffmpeg() func() {
+	ffmpeg_slots_lock.Lock()
+	slots := ffmpeg_slots
+	ffmpeg_slots_lock.Unlock()
+	if slots == nil {
+		return func() {}
+	}
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+func run_ffmpeg(args ...string) error {
+	return run_ffmpeg_to(nil, args...)
+}
+
+// run_ffmpeg_to runs ffmpeg with its standard output sent to stdout
+func run_ffmpeg_to(stdout io.Writer, args ...string) error {
+	release := acquire_ffmpeg()
+	defer release()
+	cmd := exec.Command(converter_path(), args...)
+	cmd.Stdout = stdout
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("ffmpeg failed: %v: %s", err, msg)
+		}
+		return fmt.Errorf("ffmpeg failed: %v", err)
+	}
+	return nil
+}
+
+func write_temp_wav(wav_data []byte) (string, error) {
+	tmp, err := fd_or_tempfile("", true)
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	if _, err := tmp.Write(wav_data); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// encoder_args selects the codec and its quality settings for format
+func encoder_args(format string, opts ExportOptions) ([]string, error) {
+	switch format {
+	case "opus":
+		// the ogg muxer would pick vorbis
+		return []string{"-c:a", "libopus"}, nil
+	case "ogg":
+		args := []string{"-c:a", "libvorbis"}
+		if opts.VorbisQuality != 0 || opts.VorbisQualitySet {
+			if opts.VorbisQuality < -1 || opts.VorbisQuality > 10 {
+				return nil, fmt.Errorf("Invalid vorbis quality %g, expected -1..10", opts.VorbisQuality)
+			}
+			args = append(args, "-q:a", strconv.FormatFloat(opts.VorbisQuality, 'g', -1, 64))
+		}
+		return args, nil
+	}
+	return nil, nil
+}
+
+func encode_error(target string, format string, codec []string, err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "Unknown encoder") || strings.Contains(msg, "Encoder not found") {
+		encoder := format
+		if len(codec) >= 2 {
+			encoder = codec[1]
+		}
+		return fmt.Errorf("Encoding %s failed: this ffmpeg build has no %s encoder: %v", target, encoder, err)
+	}
+	return fmt.Errorf("Encoding %s failed: %v", target, err)
+}
+
+func ffmpeg_encode(wav_file string, out_f string, format string, opts ExportOptions) error {
+	codec, err := encoder_args(format, opts)
+	if err != nil {
+		return err
+	}
+	args := append([]string{"-y", "-f", "wav", "-i", wav_file}, codec...)
+	if err := run_ffmpeg(append(args, "-f", container(ffmpeg_muxers, format), out_f)...); err != nil {
+		return encode_error(out_f, format, codec, err)
+	}
+	return nil
+}
+
+// ffprobe returns the value of entry, e.g. format=duration, reported for file
+func ffprobe(file string, entry string, args ...string) (string, error) {
+	release := acquire_ffmpeg()
+	defer release()
+	args = append([]string{"-v", "error"}, args...)
+	args = append(args, "-show_entries", entry, "-of", "default=noprint_wrappers=1:nokey=1", file)
+	cmd := exec.Command(prober_path(), args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("ffprobe failed on %s: %v: %s", file, err, msg)
+		}
+		return "", fmt.Errorf("ffprobe failed on %s: %v", file, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func ffprobe_duration(file string) (time.Duration, error) {
+	out, err := ffprobe(file, "format=duration")
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.ParseFloat(out, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe reported no duration for %s", file)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ffprobe_channels returns the channel count of the first audio stream
+func ffprobe_channels(file string) (uint16, error) {
+	out, err := ffprobe(file, "stream=channels", "-select_streams", "a:0")
+	if err != nil {
+		return 0, err
+	}
+	channels, err := strconv.ParseUint(out, 10, 16)
+	if err != nil || channels == 0 {
+		return 0, fmt.Errorf("ffprobe reported no audio channels for %s", file)
+	}
+	return uint16(channels), nil
+}
+
+func ffmpeg_decode(file string, format string, output_args ...string) (*AudioSegment, error) {
+	tmp, err := fd_or_tempfile("", true)
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	args := append([]string{"-y", "-f", container(ffmpeg_demuxers, format), "-i", file, "-vn"}, output_args...)
+	args = append(args, "-f", "wav", tmp.Name())
+	if err := run_ffmpeg(args...); err != nil {
+		return nil, fmt.Errorf("Decoding %s failed: %v", file, err)
+	}
+	return from_safe_wav(tmp.Name())
+}
+
+func ffmpeg_encode_to(wav_file string, w io.Writer, format string, opts ExportOptions) error {
+	codec, err := encoder_args(format, opts)
+	if err != nil {
+		return err
+	}
+	args := append([]string{"-y", "-f", "wav", "-i", wav_file}, codec...)
+	muxer := container(ffmpeg_muxers, format)
+	if muxer == "ipod" {
+		// a pipe can't be seeked back to write the moov atom at the end
+		args = append(args, "-movflags", "frag_keyframe+empty_moov")
+	}
+	if err := run_ffmpeg_to(w, append(args, "-f", muxer, "pipe:1")...); err != nil {
+		return encode_error(format, format, codec, err)
+	}
+	return nil
+}
+
+var mime_types = map[string]string{
+	"aac":  "audio/aac",
+	"aiff": "audio/aiff",
+	"flac": "audio/flac",
+	"m4a":  "audio/mp4",
+	"mp3":  "audio/mpeg",
+	"ogg":  "audio/ogg",
+	"opus": "audio/ogg",
+	"wav":  "audio/wav",
+}
+
+// DataURI encodes the segment as format and returns it as a base64 data: URI
+func (p *AudioSegment) DataURI(format string) (string, error) {
+	var encoded bytes.Buffer
+	if err := p.ExportTo(&encoded, format, ExportOptions{}); err != nil {
+		return "", err
+	}
+	mime, ok := mime_types[format]
+	if !ok {
+		mime = "audio/" + format
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(encoded.Bytes()), nil
+}
+
+// ExportAll writes the segment once per format -> path entry of outs. The wav
+// encoding and the temporary file fed to ffmpeg are shared by all outputs.
+// Errors name the format that failed.
+func (p *AudioSegment) ExportAll(outs map[string]string) error {
+	return p.ExportAllWithOptions(outs, ExportOptions{})
+}
+
+// ExportAllWithOptions is ExportAll with the same options for every output
+func (p *AudioSegment) ExportAllWithOptions(outs map[string]string, opts ExportOptions) (err error) {
+	defer recover_clipped(&err)
+	wav_data, err := p.encode_wav(opts)
+	if err != nil {
+		return err
+	}
+
+	formats := make([]string, 0, len(outs))
+	for format := range outs {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	tmp := ""
+	defer func() {
+		if tmp != "" {
+			os.Remove(tmp)
+		}
+	}()
+	for _, format := range formats {
+		out_f := outs[format]
+		if format == "wav" {
+			if err := ioutil.WriteFile(out_f, wav_data, 0644); err != nil {
+				return fmt.Errorf("Exporting wav to %s failed: %v", out_f, err)
+			}
+			continue
+		}
+		if tmp == "" {
+			if tmp, err = write_temp_wav(wav_data); err != nil {
+				return err
+			}
+		}
+		if err := ffmpeg_encode(tmp, out_f, format, opts); err != nil {
+			return fmt.Errorf("Exporting %s to %s failed: %v", format, out_f, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package AudioSegment
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFFmpegContainers(t *testing.T) {
+	cases := []struct {
+		format, demuxer, muxer string
+	}{
+		{"aac", "aac", "adts"},
+		{"aiff", "aiff", "aiff"},
+		{"flac", "flac", "flac"},
+		{"m4a", "mov", "ipod"},
+		{"mp3", "mp3", "mp3"},
+		{"ogg", "ogg", "ogg"},
+		{"opus", "ogg", "ogg"},
+		{"wma", "asf", "asf"},
+	}
+	for _, c := range cases {
+		if !supported_formats[c.format] {
+			t.Errorf("%s isn't a supported format", c.format)
+		}
+		if got := container(ffmpeg_demuxers, c.format); got != c.demuxer {
+			t.Errorf("%s: demuxer %s, want %s", c.format, got, c.demuxer)
+		}
+		if got := container(ffmpeg_muxers, c.format); got != c.muxer {
+			t.Errorf("%s: muxer %s, want %s", c.format, got, c.muxer)
+		}
+	}
+}
+
+// fake_tools points ffmpeg and ffprobe at scripts: ffprobe reports channels
+// and ffmpeg records its arguments to the returned file and writes wav as its
+// output
+func fake_tools(t *testing.T, channels int, wav string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tools are shell scripts")
+	}
+	dir := t.TempDir()
+	args := filepath.Join(dir, "args")
+	scripts := map[string]string{
+		"ffprobe": fmt.Sprintf("#!/bin/sh\necho %d\n", channels),
+		"ffmpeg":  fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\nfor last; do :; done\ncp %s \"$last\"\n", args, wav),
+	}
+	for name, script := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	SetFFmpegPath(filepath.Join(dir, "ffmpeg"))
+	SetFFprobePath(filepath.Join(dir, "ffprobe"))
+	t.Cleanup(func() {
+		SetFFmpegPath("ffmpeg")
+		SetFFprobePath("ffprobe")
+	})
+	return args
+}
+
+func TestFromFileChannelsOnlyDownmixes(t *testing.T) {
+	wav := filepath.Join(t.TempDir(), "decoded.wav")
+	SineWave(440, 50, 8000, 2).Export(wav, "wav")
+	cases := []struct {
+		source, channels int
+		downmix          bool
+	}{
+		{6, 2, true},
+		{2, 1, true},
+		{2, 2, false},
+		{1, 2, false},
+	}
+	for _, c := range cases {
+		args := fake_tools(t, c.source, wav)
+		if _, err := FromFileChannels("in.mp3", "mp3", uint16(c.channels)); err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadFile(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf("-ac %d", c.channels)
+		if got := strings.Contains(string(out), want); got != c.downmix {
+			t.Errorf("%d channels to %d: ffmpeg ran with %q", c.source, c.channels, strings.TrimSpace(string(out)))
+		}
+	}
+}
+
+func TestExportAll(t *testing.T) {
+	dir := t.TempDir()
+	// an ffmpeg that encodes nothing
+	fail := filepath.Join(dir, "ffmpeg")
+	if err := ioutil.WriteFile(fail, []byte("#!/bin/sh\necho no encoder >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	SetFFmpegPath(fail)
+	defer SetFFmpegPath("ffmpeg")
+	seg := SineWave(440, 100, 8000, 2)
+	cases := []struct {
+		name string
+		outs map[string]string
+		// ExportAll when nil
+		opts *ExportOptions
+		err  string
+	}{
+		{"wav", map[string]string{"wav": filepath.Join(dir, "a.wav")}, nil, ""},
+		{"wav with options", map[string]string{"wav": filepath.Join(dir, "b.wav")}, &ExportOptions{FloatOutput: true}, ""},
+		{"failing format", map[string]string{"wav": filepath.Join(dir, "c.wav"), "mp3": filepath.Join(dir, "c.mp3")}, nil, "Exporting mp3"},
+	}
+	for _, c := range cases {
+		opts := ExportOptions{}
+		var err error
+		if c.opts == nil {
+			err = seg.ExportAll(c.outs)
+		} else {
+			opts = *c.opts
+			err = seg.ExportAllWithOptions(c.outs, opts)
+		}
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: error %v, want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		want, err := seg.encode_wav(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(c.outs["wav"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: the wav wasn't written with the options", c.name)
+		}
+	}
+}
+
+func TestVorbisQualityArgs(t *testing.T) {
+	cases := []struct {
+		name string
+		opts ExportOptions
+		want string
+	}{
+		{"default", ExportOptions{}, "-c:a libvorbis"},
+		{"quality 5", ExportOptions{VorbisQuality: 5}, "-c:a libvorbis -q:a 5"},
+		{"quality 0", ExportOptions{VorbisQualitySet: true}, "-c:a libvorbis -q:a 0"},
+		{"quality -1", ExportOptions{VorbisQuality: -1, VorbisQualitySet: true}, "-c:a libvorbis -q:a -1"},
+	}
+	for _, c := range cases {
+		args, err := encoder_args("ogg", c.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := strings.Join(args, " "); got != c.want {
+			t.Errorf("%s: %q, want %q", c.name, got, c.want)
+		}
+	}
+	if _, err := encoder_args("ogg", ExportOptions{VorbisQuality: 11}); err == nil {
+		t.Errorf("quality 11 was accepted")
+	}
+}
+
+func TestFromFileChannelsErrors(t *testing.T) {
+	dir := t.TempDir()
+	// an ffprobe that finds no stream
+	fail := filepath.Join(dir, "ffprobe")
+	if err := ioutil.WriteFile(fail, []byte("#!/bin/sh\necho no such file >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	SetFFprobePath(fail)
+	defer SetFFprobePath("ffprobe")
+	cases := []struct {
+		name, file, format string
+		channels           uint16
+	}{
+		{"no channels", "in.wav", "wav", 0},
+		{"missing wav", filepath.Join(dir, "missing.wav"), "wav", 1},
+		{"failed probe", filepath.Join(dir, "missing.mp3"), "mp3", 1},
+	}
+	for _, c := range cases {
+		if seg, err := FromFileChannels(c.file, c.format, c.channels); err == nil || seg != nil {
+			t.Errorf("%s: got %v, %v, want an error", c.name, seg, err)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package AudioSegment
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// longest window handed to the FFT by the spectral helpers
+const max_fft_frames = 1 << 20
+
+func next_pow2(n int) int {
+	size := 1
+	for size < n {
+		size <<= 1
+	}
+	return size
+}
+
+// fft is an in-place iterative radix-2 transform, len(x) must be a power of 2
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
+
+func ifft(x []complex128) {
+	for i := range x {
+		x[i] = cmplx.Conj(x[i])
+	}
+	fft(x)
+	scale := complex(1/float64(len(x)), 0)
+	for i := range x {
+		x[i] = cmplx.Conj(x[i]) * scale
+	}
+}
+
+// mono_frames mixes frames [start, end) of every channel into one slice
+func (p *AudioSegment) mono_frames(start, end int) []float64 {
+	channels := int(p.channels)
+	width := int(p.sample_width)
+	data := *p.data
+	mono := make([]float64, end-start)
+	for i := range mono {
+		offset := (start + i) * int(p.frame_width)
+		for c := 0; c < channels; c++ {
+			mono[i] += float64(decode_sample(data[offset+c*width:], p.sample_width))
+		}
+		mono[i] /= float64(channels)
+	}
+	return mono
+}
+
+// magnitude_spectrum returns the n/2+1 bin magnitudes of the Hann windowed
+// samples zero padded to n
+func magnitude_spectrum(samples []float64, n int) []float64 {
+	x := make([]complex128, n)
+	for i, v := range samples {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(samples)))
+		x[i] = complex(v*w, 0)
+	}
+	fft(x)
+	bins := make([]float64, n/2+1)
+	for i := range bins {
+		bins[i] = cmplx.Abs(x[i])
+	}
+	return bins
+}
+
+func (p *AudioSegment) DominantFrequency() float64 {
+	return p.DominantFrequencyAt(0, p.Len())
+}
+
+// DominantFrequencyAt returns the strongest frequency in Hz within the window,
+// or 0 when the window is empty or silent
+func (p *AudioSegment) DominantFrequencyAt(startMs, lengthMs int) float64 {
+	start := p.FrameCountMs(startMs)
+	end := start + p.FrameCountMs(lengthMs)
+	if start < 0 {
+		start = 0
+	}
+	if end > p.FrameCount() {
+		end = p.FrameCount()
+	}
+	if end-start > max_fft_frames {
+		end = start + max_fft_frames
+	}
+	if end-start < 2 {
+		return 0
+	}
+
+	n := next_pow2(end - start)
+	bins := magnitude_spectrum(p.mono_frames(start, end), n)
+	peak := 1
+	for i := 2; i < len(bins); i++ {
+		if bins[i] > bins[peak] {
+			peak = i
+		}
+	}
+	if bins[peak] == 0 {
+		return 0
+	}
+
+	// parabolic interpolation between the neighbouring bins
+	offset := 0.0
+	if peak+1 < len(bins) {
+		a, b, c := math.Log(bins[peak-1]+1e-12), math.Log(bins[peak]+1e-12), math.Log(bins[peak+1]+1e-12)
+		if denom := a - 2*b + c; denom != 0 {
+			offset = 0.5 * (a - c) / denom
+		}
+	}
+	return (float64(peak) + offset) * float64(p.frame_rate) / float64(n)
+}
+
+const centroid_window_ms = 50
+
+// Spectrum averages the magnitude spectra of consecutive windowMs windows.
+// Bin i covers i*frame_rate/n Hz, with n = 2*(len(bins)-1).
+func (p *AudioSegment) Spectrum(windowMs int) []float64 {
+	size := p.FrameCountMs(windowMs)
+	if size < 2 {
+		errmsg := fmt.Sprintf("Spectrum window of %dms is too short", windowMs)
+		panic(errmsg)
+	}
+	n := next_pow2(size)
+	bins := make([]float64, n/2+1)
+	frames := p.FrameCount()
+	if frames < size {
+		size = frames
+	}
+	windows := 0
+	for start := 0; size > 1 && start+size <= frames; start += size {
+		for i, v := range magnitude_spectrum(p.mono_frames(start, start+size), n) {
+			bins[i] += v
+		}
+		windows++
+	}
+	for i := range bins {
+		if windows > 0 {
+			bins[i] /= float64(windows)
+		}
+	}
+	return bins
+}
+
+// SpectralCentroid is the magnitude weighted mean frequency in Hz
+func (p *AudioSegment) SpectralCentroid() float64 {
+	bins := p.Spectrum(centroid_window_ms)
+	n := 2 * (len(bins) - 1)
+	var weighted, total float64
+	for i, m := range bins {
+		weighted += float64(i) * float64(p.frame_rate) / float64(n) * m
+		total += m
+	}
+	if total == 0 {
+		return 0
+	}
+	return weighted / total
+}
+
+// THD measures the total harmonic distortion of a pure tone at fundamentalHz:
+// the RMS of its harmonics up to the Nyquist frequency relative to the
+// fundamental, as a ratio (0.01 is 1%). Silence measures 0. The first
+// max_fft_frames frames are analyzed.
+func (p *AudioSegment) THD(fundamentalHz float64) float64 {
+	if fundamentalHz <= 0 || fundamentalHz >= float64(p.frame_rate)/2 {
+		errmsg := fmt.Sprintf("Invalid fundamental %gHz at %dHz", fundamentalHz, p.frame_rate)
+		panic(errmsg)
+	}
+	frames := p.FrameCount()
+	if frames > max_fft_frames {
+		frames = max_fft_frames
+	}
+	if frames < 2 {
+		return 0
+	}
+	n := next_pow2(frames)
+	bins := magnitude_spectrum(p.mono_frames(0, frames), n)
+	bin_hz := float64(p.frame_rate) / float64(n)
+	// the Hann main lobe spans two bins of the unpadded window either way
+	lobe := int(math.Ceil(2*float64(n)/float64(frames))) + 1
+
+	power := func(hz float64) float64 {
+		center := int(math.Round(hz / bin_hz))
+		var sum float64
+		for i := center - lobe; i <= center+lobe; i++ {
+			if i >= 0 && i < len(bins) {
+				sum += bins[i] * bins[i]
+			}
+		}
+		return sum
+	}
+	fundamental := power(fundamentalHz)
+	if fundamental == 0 {
+		return 0
+	}
+	var harmonics float64
+	for k := 2; float64(k)*fundamentalHz < float64(p.frame_rate)/2; k++ {
+		harmonics += power(float64(k) * fundamentalHz)
+	}
+	return math.Sqrt(harmonics / fundamental)
+}
+
+// best_lag returns the lag in [min_lag, max_lag] maximizing the
+// cross-correlation sum(a[t] * b[t-lag]), computed through the FFT
+func best_lag(a, b []float64, min_lag, max_lag int) int {
+	n := next_pow2(len(a) + len(b))
+	x := make([]complex128, n)
+	y := make([]complex128, n)
+	for i, v := range a {
+		x[i] = complex(v, 0)
+	}
+	for i, v := range b {
+		y[i] = complex(v, 0)
+	}
+	fft(x)
+	fft(y)
+	for i := range x {
+		x[i] *= cmplx.Conj(y[i])
+	}
+	ifft(x)
+
+	// negative lags wrap around to the end of the result
+	best, best_value := 0, math.Inf(-1)
+	for lag := min_lag; lag <= max_lag; lag++ {
+		if lag >= len(a) || -lag >= len(b) {
+			continue
+		}
+		if v := real(x[(lag+n)%n]); v > best_value {
+			best, best_value = lag, v
+		}
+	}
+	return best
+}
+
+// FindOffset returns the lag in ms, within ±maxLagMs, at which other best
+// matches the segment: a positive lag means the audio of other starts that
+// much later in p, so other.OverlayRange at the lag lines them up. Both are
+// mixed to mono and at most the first max_fft_frames/2 frames are compared.
+func (p *AudioSegment) FindOffset(other *AudioSegment, maxLagMs int) int {
+	if p.frame_rate != other.frame_rate {
+		errmsg := fmt.Sprintf("Can't correlate audio at %dHz with audio at %dHz", p.frame_rate, other.frame_rate)
+		panic(errmsg)
+	}
+	if maxLagMs < 0 {
+		errmsg := fmt.Sprintf("Invalid maximum lag %dms", maxLagMs)
+		panic(errmsg)
+	}
+	limit := func(frames int) int {
+		if frames > max_fft_frames/2 {
+			return max_fft_frames / 2
+		}
+		return frames
+	}
+	a := p.mono_frames(0, limit(p.FrameCount()))
+	b := other.mono_frames(0, limit(other.FrameCount()))
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	max_lag := p.FrameCountMs(maxLagMs)
+	return p.frame_to_ms(best_lag(a, b, -max_lag, max_lag))
+}
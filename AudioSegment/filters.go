@@ -0,0 +1,265 @@
+package AudioSegment
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// biquad holds normalized direct form I coefficients (a0 == 1)
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+type biquad_state struct {
+	x1, x2, y1, y2 float64
+}
+
+func (f *biquad) process(s *biquad_state, x float64) float64 {
+	y := f.b0*x + f.b1*s.x1 + f.b2*s.x2 - f.a1*s.y1 - f.a2*s.y2
+	s.x2, s.x1 = s.x1, x
+	s.y2, s.y1 = s.y1, y
+	return y
+}
+
+func (f *biquad) response(freq float64, rate float64) float64 {
+	z := cmplx.Exp(complex(0, -2*math.Pi*freq/rate))
+	num := complex(f.b0, 0) + complex(f.b1, 0)*z + complex(f.b2, 0)*z*z
+	den := 1 + complex(f.a1, 0)*z + complex(f.a2, 0)*z*z
+	return cmplx.Abs(num / den)
+}
+
+// bilinear maps the analog section (b[0]s^2 + b[1]s + b[2]) / (a[0]s^2 + a[1]s + a[2])
+// to a digital biquad
+func bilinear(b [3]float64, a [3]float64, rate float64) biquad {
+	k := 2 * rate
+	k2 := k * k
+	a0 := a[0]*k2 + a[1]*k + a[2]
+	return biquad{
+		b0: (b[0]*k2 + b[1]*k + b[2]) / a0,
+		b1: (2*b[2] - 2*b[0]*k2) / a0,
+		b2: (b[0]*k2 - b[1]*k + b[2]) / a0,
+		a1: (2*a[2] - 2*a[0]*k2) / a0,
+		a2: (a[0]*k2 - a[1]*k + a[2]) / a0,
+	}
+}
+
+// a_weighting approximates the IEC 61672 A-weighting curve with three biquads,
+// normalized to unity gain at 1kHz
+func a_weighting(rate float64) []biquad {
+	w1 := 2 * math.Pi * 20.598997
+	w2 := 2 * math.Pi * 107.65265
+	w3 := 2 * math.Pi * 737.86223
+	w4 := 2 * math.Pi * 12194.217
+	filters := []biquad{
+		bilinear([3]float64{1, 0, 0}, [3]float64{1, 2 * w1, w1 * w1}, rate),
+		bilinear([3]float64{1, 0, 0}, [3]float64{1, w2 + w3, w2 * w3}, rate),
+		bilinear([3]float64{0, 0, 1}, [3]float64{1, 2 * w4, w4 * w4}, rate),
+	}
+	gain := 1.0
+	for i := range filters {
+		gain *= filters[i].response(1000, rate)
+	}
+	filters[0].b0 /= gain
+	filters[0].b1 /= gain
+	filters[0].b2 /= gain
+	return filters
+}
+
+// filter_channels runs the cascade over every channel, each with its own state
+func filter_channels(channels [][]float64, filters []biquad) [][]float64 {
+	out := make([][]float64, len(channels))
+	for c, samples := range channels {
+		states := make([]biquad_state, len(filters))
+		filtered := make([]float64, len(samples))
+		for i, x := range samples {
+			for j := range filters {
+				x = filters[j].process(&states[j], x)
+			}
+			filtered[i] = x
+		}
+		out[c] = filtered
+	}
+	return out
+}
+
+// filter_channels_zero_phase runs the cascade forwards then backwards, which
+// cancels its phase shift and squares its magnitude response
+func filter_channels_zero_phase(channels [][]float64, filters []biquad) [][]float64 {
+	out := filter_channels(channels, filters)
+	for _, samples := range out {
+		reverse_floats(samples)
+	}
+	out = filter_channels(out, filters)
+	for _, samples := range out {
+		reverse_floats(samples)
+	}
+	return out
+}
+
+func reverse_floats(x []float64) {
+	for i, j := 0, len(x)-1; i < j; i, j = i+1, j-1 {
+		x[i], x[j] = x[j], x[i]
+	}
+}
+
+func rms_floats(channels [][]float64) float64 {
+	var sum float64
+	count := 0
+	for _, samples := range channels {
+		for _, v := range samples {
+			sum += v * v
+		}
+		count += len(samples)
+	}
+	if count == 0 {
+		return 0
+	}
+	return math.Sqrt(sum / float64(count))
+}
+
+// NormalizeAWeighted measures the A-weighted level of the segment and applies
+// the gain that brings it to targetDB. The weighting is only used for the
+// measurement, the returned audio is the original with makeup gain.
+func (p *AudioSegment) NormalizeAWeighted(targetDB float64) *AudioSegment {
+	weighted := filter_channels(p.channel_floats(), a_weighting(float64(p.frame_rate)))
+	level := ratio_to_db(rms_floats(weighted) / p.max_possible_amplitude())
+	if math.IsInf(level, -1) {
+		return p.ApplyGain(0)
+	}
+	return p.ApplyGain(targetDB - level)
+}
+
+// highpass_biquad is the RBJ cookbook high-pass
+func highpass_biquad(freq, q, rate float64) biquad {
+	w0 := 2 * math.Pi * freq / rate
+	alpha := math.Sin(w0) / (2 * q)
+	cos_w0 := math.Cos(w0)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 + cos_w0) / 2 / a0,
+		b1: -(1 + cos_w0) / a0,
+		b2: (1 + cos_w0) / 2 / a0,
+		a1: -2 * cos_w0 / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+const default_deess_hz = 7000
+
+// DeEss splits every channel at centerHz into a high band and the remainder,
+// and turns the high band down by up to reductionDB while its envelope is over
+// thresholdDB. The remainder passes untouched. centerHz <= 0 uses 7kHz.
+func (p *AudioSegment) DeEss(centerHz float64, thresholdDB float64, reductionDB float64) *AudioSegment {
+	if centerHz <= 0 {
+		centerHz = default_deess_hz
+	}
+	rate := float64(p.frame_rate)
+	if centerHz >= rate/2 {
+		errmsg := fmt.Sprintf("De-esser frequency %gHz is above the Nyquist frequency", centerHz)
+		panic(errmsg)
+	}
+	if reductionDB < 0 {
+		reductionDB = -reductionDB
+	}
+	split := []biquad{highpass_biquad(centerHz, math.Sqrt2/2, rate)}
+	threshold := db_to_ratio(thresholdDB) * p.max_possible_amplitude()
+	attack := p.smoothing_coefficient(1)
+	release := p.smoothing_coefficient(50)
+
+	channels := p.channel_floats()
+	// zero phase so that the remainder x - high holds no trace of the band
+	highs := filter_channels_zero_phase(channels, split)
+	for c, samples := range channels {
+		var envelope float64
+		for i, x := range samples {
+			high := highs[c][i]
+			level := math.Abs(high)
+			if level > envelope {
+				envelope = level + attack*(envelope-level)
+			} else {
+				envelope = level + release*(envelope-level)
+			}
+			gain := 1.0
+			if envelope > threshold {
+				over := ratio_to_db(envelope / threshold)
+				gain = db_to_ratio(-math.Min(over, reductionDB))
+			}
+			samples[i] = (x - high) + high*gain
+		}
+		channels[c] = samples
+	}
+	return p.spawn_channel_floats(channels)
+}
+
+// peaking_biquad is the RBJ cookbook peaking EQ
+func peaking_biquad(freq, gain_db, q, rate float64) biquad {
+	w0 := 2 * math.Pi * freq / rate
+	alpha := math.Sin(w0) / (2 * q)
+	cos_w0 := math.Cos(w0)
+	a := math.Pow(10, gain_db/40)
+	a0 := 1 + alpha/a
+	return biquad{
+		b0: (1 + alpha*a) / a0,
+		b1: -2 * cos_w0 / a0,
+		b2: (1 - alpha*a) / a0,
+		a1: -2 * cos_w0 / a0,
+		a2: (1 - alpha/a) / a0,
+	}
+}
+
+// shelf_biquad is the RBJ cookbook low shelf, or high shelf when high is set
+func shelf_biquad(freq, gain_db, q, rate float64, high bool) biquad {
+	w0 := 2 * math.Pi * freq / rate
+	alpha := math.Sin(w0) / (2 * q)
+	cos_w0 := math.Cos(w0)
+	a := math.Pow(10, gain_db/40)
+	// the high shelf mirrors the low shelf around half the sample rate
+	sign := 1.0
+	if high {
+		sign = -1
+	}
+	edge := 2 * math.Sqrt(a) * alpha
+	a0 := (a + 1) + sign*(a-1)*cos_w0 + edge
+	return biquad{
+		b0: a * ((a + 1) - sign*(a-1)*cos_w0 + edge) / a0,
+		b1: sign * 2 * a * ((a - 1) - sign*(a+1)*cos_w0) / a0,
+		b2: a * ((a + 1) - sign*(a-1)*cos_w0 - edge) / a0,
+		a1: -sign * 2 * ((a - 1) + sign*(a+1)*cos_w0) / a0,
+		a2: ((a + 1) + sign*(a-1)*cos_w0 - edge) / a0,
+	}
+}
+
+func (p *AudioSegment) check_eq_band(freq, q float64) {
+	if freq <= 0 || freq >= float64(p.frame_rate)/2 {
+		errmsg := fmt.Sprintf("EQ frequency %gHz is outside 0Hz-%dHz", freq, p.frame_rate/2)
+		panic(errmsg)
+	}
+	if q <= 0 {
+		errmsg := fmt.Sprintf("Invalid EQ Q %g", q)
+		panic(errmsg)
+	}
+}
+
+// PeakingEQ boosts or cuts gainDB around centerHz, over a bandwidth narrowing
+// as q grows
+func (p *AudioSegment) PeakingEQ(centerHz, gainDB, q float64) *AudioSegment {
+	p.check_eq_band(centerHz, q)
+	filter := peaking_biquad(centerHz, gainDB, q, float64(p.frame_rate))
+	return p.spawn_channel_floats(filter_channels(p.channel_floats(), []biquad{filter}))
+}
+
+// LowShelf boosts or cuts gainDB below freqHz, q sets the steepness of the
+// transition (0.707 for no overshoot)
+func (p *AudioSegment) LowShelf(freqHz, gainDB, q float64) *AudioSegment {
+	p.check_eq_band(freqHz, q)
+	filter := shelf_biquad(freqHz, gainDB, q, float64(p.frame_rate), false)
+	return p.spawn_channel_floats(filter_channels(p.channel_floats(), []biquad{filter}))
+}
+
+// HighShelf boosts or cuts gainDB above freqHz, like LowShelf
+func (p *AudioSegment) HighShelf(freqHz, gainDB, q float64) *AudioSegment {
+	p.check_eq_band(freqHz, q)
+	filter := shelf_biquad(freqHz, gainDB, q, float64(p.frame_rate), true)
+	return p.spawn_channel_floats(filter_channels(p.channel_floats(), []biquad{filter}))
+}
@@ -0,0 +1,88 @@
+package AudioSegment
+
+import (
+	"math"
+	"testing"
+)
+
+type eq_case struct {
+	name   string
+	tone   float64
+	filter func(*AudioSegment) *AudioSegment
+	gain   float64
+}
+
+// check_eq measures how much each filter changes the level of its tone,
+// leaving out the settling of the filter at the start
+func check_eq(t *testing.T, cases []eq_case) {
+	for _, c := range cases {
+		src := SineWave(c.tone, 1000, 44100, 2).ApplyGain(-12)
+		out := c.filter(src)
+		start, end := src.FrameCountMs(100), src.FrameCountMs(1000)
+		gain := out.SliceFrames(start, end).DBFS() - src.SliceFrames(start, end).DBFS()
+		if math.Abs(gain-c.gain) > 0.5 {
+			t.Errorf("%s at %gHz: gain is %.2fdB, want %gdB", c.name, c.tone, gain, c.gain)
+		}
+	}
+}
+
+func TestPeakingEQ(t *testing.T) {
+	peak := func(p *AudioSegment) *AudioSegment { return p.PeakingEQ(1000, 6, 1) }
+	cut := func(p *AudioSegment) *AudioSegment { return p.PeakingEQ(1000, -6, 1) }
+	check_eq(t, []eq_case{
+		{"+6dB peak", 1000, peak, 6},
+		{"+6dB peak", 100, peak, 0},
+		{"+6dB peak", 10000, peak, 0},
+		{"-6dB peak", 1000, cut, -6},
+	})
+}
+
+func TestShelves(t *testing.T) {
+	low := func(p *AudioSegment) *AudioSegment { return p.LowShelf(200, 6, 0.707) }
+	high := func(p *AudioSegment) *AudioSegment { return p.HighShelf(2000, 6, 0.707) }
+	check_eq(t, []eq_case{
+		{"+6dB low shelf", 50, low, 6},
+		{"+6dB low shelf", 5000, low, 0},
+		{"+6dB high shelf", 10000, high, 6},
+		{"+6dB high shelf", 100, high, 0},
+	})
+}
+
+func TestDeEss(t *testing.T) {
+	low := SineWave(200, 1000, 44100, 2).ApplyGain(-12)
+	cases := []struct {
+		name    string
+		burstDB float64
+		// range of the change of the band level, the band edge below 12kHz
+		// partly escapes the split so a 12dB reduction is never reached
+		min, max float64
+	}{
+		{"loud band", -12, -12, -9},
+		{"band under the threshold", -40, -0.5, 0.5},
+	}
+	start, end := low.FrameCountMs(100), low.FrameCountMs(900)
+	rms := func(x []float64) float64 {
+		var sum float64
+		for _, v := range x[start:end] {
+			sum += v * v
+		}
+		return math.Sqrt(sum / float64(end-start))
+	}
+	for _, c := range cases {
+		burst := SineWave(12000, 1000, 44100, 2).ApplyGain(c.burstDB)
+		out := low.Overlay(burst).DeEss(7000, -30, 12)
+		// what is left after taking the untouched low tone away is the band
+		band, dry := out.GetFloatSamples(), low.GetFloatSamples()
+		for i := range band {
+			band[i] -= dry[i]
+		}
+		gain := ratio_to_db(rms(band) / rms(burst.GetFloatSamples()))
+		if gain < c.min || gain > c.max {
+			t.Errorf("%s: band changed by %.2fdB, want %gdB to %gdB", c.name, gain, c.min, c.max)
+		}
+	}
+	// without any band content the low tone passes unchanged
+	if out := low.DeEss(7000, -30, 12); !out.ApproxEqual(low, 0.001) {
+		t.Errorf("the low tone alone was changed")
+	}
+}
@@ -0,0 +1,114 @@
+package AudioSegment
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// fingerprint parameters after Haitsma & Kalker: 370ms frames every 11.6ms,
+// 33 log spaced bands between 300Hz and 2kHz giving 32 bits per frame
+const (
+	fingerprint_rate      = 5512
+	fingerprint_frame     = 2048
+	fingerprint_hop       = 64
+	fingerprint_bands     = 33
+	fingerprint_low_hz    = 300
+	fingerprint_high_hz   = 2000
+	fingerprint_max_shift = 16
+)
+
+// lowpass_biquad is the RBJ cookbook low-pass
+func lowpass_biquad(freq, q, rate float64) biquad {
+	w0 := 2 * math.Pi * freq / rate
+	alpha := math.Sin(w0) / (2 * q)
+	cos_w0 := math.Cos(w0)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 - cos_w0) / 2 / a0,
+		b1: (1 - cos_w0) / a0,
+		b2: (1 - cos_w0) / 2 / a0,
+		a1: -2 * cos_w0 / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// Fingerprint returns one 32-bit word per 11.6ms of audio describing how the
+// energy moves between neighbouring bands, which survives lossy encoding and
+// level changes. Compare fingerprints with FingerprintSimilarity.
+func (p *AudioSegment) Fingerprint() []uint32 {
+	rate := float64(p.frame_rate)
+	mono := p.mono_frames(0, p.FrameCount())
+	// drop what the resampling would fold into the bands
+	if cutoff := 0.45 * fingerprint_rate; cutoff < rate/2 {
+		lowpass := []biquad{lowpass_biquad(cutoff, math.Sqrt2/2, rate), lowpass_biquad(cutoff, math.Sqrt2/2, rate)}
+		mono = filter_channels([][]float64{mono}, lowpass)[0]
+	}
+	mono_seg := &AudioSegment{channels: 1, frame_rate: p.frame_rate, sample_width: 4, frame_width: 4}
+	mono_seg = mono_seg.spawn_channel_floats([][]float64{mono})
+	frames := int(math.Round(float64(len(mono)) * fingerprint_rate / rate))
+	samples := mono_seg.resample_frames(frames).channel_floats()[0]
+
+	edges := make([]int, fingerprint_bands+1)
+	for i := range edges {
+		hz := fingerprint_low_hz * math.Pow(fingerprint_high_hz/fingerprint_low_hz, float64(i)/fingerprint_bands)
+		edges[i] = int(hz * fingerprint_frame / fingerprint_rate)
+	}
+	window := make([]float64, fingerprint_frame)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/fingerprint_frame)
+	}
+
+	prints := make([]uint32, 0)
+	prev := make([]float64, fingerprint_bands)
+	energy := make([]float64, fingerprint_bands)
+	x := make([]complex128, fingerprint_frame)
+	for start := 0; start+fingerprint_frame <= len(samples); start += fingerprint_hop {
+		for i := range x {
+			x[i] = complex(samples[start+i]*window[i], 0)
+		}
+		fft(x)
+		for b := range energy {
+			energy[b] = 0
+			for k := edges[b]; k < edges[b+1]; k++ {
+				m := cmplx.Abs(x[k])
+				energy[b] += m * m
+			}
+		}
+		if start > 0 {
+			var word uint32
+			for b := 0; b < fingerprint_bands-1; b++ {
+				if energy[b]-energy[b+1]-(prev[b]-prev[b+1]) > 0 {
+					word |= 1 << uint(b)
+				}
+			}
+			prints = append(prints, word)
+		}
+		copy(prev, energy)
+	}
+	return prints
+}
+
+// FingerprintSimilarity is the fraction of matching bits of two fingerprints,
+// from 0.5 for unrelated audio to 1 for the same recording, at the best
+// alignment within fingerprint_max_shift words. Fingerprints sharing only a
+// few words score 0.
+func FingerprintSimilarity(a, b []uint32) float64 {
+	best := 0.0
+	for shift := -fingerprint_max_shift; shift <= fingerprint_max_shift; shift++ {
+		matched, total := 0, 0
+		for i := range a {
+			j := i + shift
+			if j < 0 || j >= len(b) {
+				continue
+			}
+			matched += 32 - bits.OnesCount32(a[i]^b[j])
+			total += 32
+		}
+		if total < 32*fingerprint_max_shift {
+			continue
+		}
+		best = math.Max(best, float64(matched)/float64(total))
+	}
+	return best
+}
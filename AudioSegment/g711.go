@@ -0,0 +1,127 @@
+package AudioSegment
+
+import (
+	"fmt"
+)
+
+// segment ends of the G.711 companding curves, on 13-bit (A-law) and 14-bit
+// (µ-law) magnitudes
+var (
+	alaw_segment_ends = [8]int32{0x1f, 0x3f, 0x7f, 0xff, 0x1ff, 0x3ff, 0x7ff, 0xfff}
+	ulaw_segment_ends = [8]int32{0x3f, 0x7f, 0xff, 0x1ff, 0x3ff, 0x7ff, 0xfff, 0x1fff}
+)
+
+func g711_segment(v int32, ends *[8]int32) int32 {
+	for seg, end := range ends {
+		if v <= end {
+			return int32(seg)
+		}
+	}
+	return 8
+}
+
+func linear_to_alaw(sample int16) byte {
+	v := int32(sample) >> 3
+	mask := int32(0xd5)
+	if v < 0 {
+		mask = 0x55
+		v = -v - 1
+	}
+	seg := g711_segment(v, &alaw_segment_ends)
+	if seg >= 8 {
+		return byte(0x7f ^ mask)
+	}
+	a := seg << 4
+	if seg < 2 {
+		a |= v >> 1 & 0xf
+	} else {
+		a |= v >> uint(seg) & 0xf
+	}
+	return byte(a ^ mask)
+}
+
+func alaw_to_linear(a byte) int16 {
+	a ^= 0x55
+	t := int32(a&0xf) << 4
+	switch seg := uint(a&0x70) >> 4; seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t = (t + 0x108) << (seg - 1)
+	}
+	if a&0x80 == 0 {
+		t = -t
+	}
+	return int16(t)
+}
+
+func linear_to_ulaw(sample int16) byte {
+	v := int32(sample) >> 2
+	mask := int32(0xff)
+	if v < 0 {
+		mask = 0x7f
+		v = -v
+	}
+	if v > 8159 {
+		v = 8159
+	}
+	v += 0x21
+	seg := g711_segment(v, &ulaw_segment_ends)
+	if seg >= 8 {
+		return byte(0x7f ^ mask)
+	}
+	return byte((seg<<4 | v>>uint(seg+1)&0xf) ^ mask)
+}
+
+func ulaw_to_linear(u byte) int16 {
+	u = ^u
+	t := (int32(u&0xf)<<3 + 0x84) << (uint(u&0x70) >> 4)
+	if u&0x80 != 0 {
+		return int16(0x84 - t)
+	}
+	return int16(t - 0x84)
+}
+
+// expand_g711 decodes A-law or µ-law data into 16-bit linear samples
+func (p *AudioSegment) expand_g711(format_tag uint16) error {
+	if p.sample_width != 1 {
+		return fmt.Errorf("G.711 wav data must be 8 bits per sample, got %d", p.sample_width*8)
+	}
+	expand := ulaw_to_linear
+	if format_tag == WavFormatALaw {
+		expand = alaw_to_linear
+	}
+	src := *p.data
+	data := make([]byte, len(src)*2)
+	for i, b := range src {
+		encode_sample(data[i*2:], 2, int32(expand(b)))
+	}
+	p.sample_width = 2
+	p.frame_width = p.channels * 2
+	p.set_data(&data)
+	return nil
+}
+
+// g711_data compands the samples to 8-bit A-law or µ-law, the result is
+// only meant to be written out as a wav of that format
+func (p *AudioSegment) g711_data(format_tag uint16) *AudioSegment {
+	seg := p
+	if seg.sample_width != 2 {
+		seg = seg.SetSampleWidth(2)
+	}
+	compress := linear_to_ulaw
+	if format_tag == WavFormatALaw {
+		compress = linear_to_alaw
+	}
+	samples := seg.samples()
+	data := make([]byte, len(samples))
+	for i, v := range samples {
+		data[i] = compress(int16(v))
+	}
+	obj := seg.spawn(&data)
+	obj.sample_width = 1
+	obj.frame_width = obj.channels
+	return obj
+}
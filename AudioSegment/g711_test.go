@@ -0,0 +1,71 @@
+package AudioSegment
+
+import "testing"
+
+var g711_laws = []struct {
+	name   string
+	encode func(int16) byte
+	decode func(byte) int16
+	// largest error of a round trip near silence, relative above that
+	floor int
+}{
+	{"A-law", linear_to_alaw, alaw_to_linear, 32},
+	{"µ-law", linear_to_ulaw, ulaw_to_linear, 36},
+}
+
+func TestG711KnownValues(t *testing.T) {
+	cases := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"linear_to_alaw(0)", int(linear_to_alaw(0)), 0xd5},
+		{"linear_to_alaw(32767)", int(linear_to_alaw(32767)), 0xaa},
+		{"linear_to_alaw(-32768)", int(linear_to_alaw(-32768)), 0x2a},
+		{"alaw_to_linear(0xd5)", int(alaw_to_linear(0xd5)), 8},
+		{"alaw_to_linear(0xaa)", int(alaw_to_linear(0xaa)), 32256},
+		{"linear_to_ulaw(0)", int(linear_to_ulaw(0)), 0xff},
+		{"linear_to_ulaw(32767)", int(linear_to_ulaw(32767)), 0x80},
+		{"linear_to_ulaw(-32768)", int(linear_to_ulaw(-32768)), 0x00},
+		{"ulaw_to_linear(0xff)", int(ulaw_to_linear(0xff)), 0},
+		{"ulaw_to_linear(0x80)", int(ulaw_to_linear(0x80)), 32124},
+		{"ulaw_to_linear(0x00)", int(ulaw_to_linear(0x00)), -32124},
+	}
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("%s is %#x, want %#x", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestG711RoundTrip(t *testing.T) {
+	for _, law := range g711_laws {
+		for x := -32768; x <= 32767; x++ {
+			d := int(law.decode(law.encode(int16(x)))) - x
+			if d < 0 {
+				d = -d
+			}
+			limit := x / 16
+			if limit < 0 {
+				limit = -limit
+			}
+			if limit < law.floor {
+				limit = law.floor
+			}
+			if d > limit {
+				t.Errorf("%s: %d comes back off by %d", law.name, x, d)
+			}
+		}
+		// decoded values are on the curve and encode to the same code
+		for c := 0; c < 256; c++ {
+			code := byte(c)
+			if law.name == "µ-law" && code == 0x7f {
+				// negative zero decodes to 0, which encodes as 0xff
+				continue
+			}
+			if got := law.encode(law.decode(code)); got != code {
+				t.Errorf("%s: code %#x comes back as %#x", law.name, code, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package AudioSegment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// the mp3 decoder itself delays the output by this many samples
+const mp3_decoder_delay = 529
+
+type lame_info struct {
+	sample_rate uint32
+	// samples in the audio frames after the Xing/Info frame
+	samples int
+	delay   int
+	padding int
+}
+
+// side information bytes of a layer III frame, mono and stereo
+var mp3_side_info = [2][2]int{
+	{9, 17},  // MPEG 2 and 2.5
+	{17, 32}, // MPEG 1
+}
+
+var mp3_sample_rates = [4][3]uint32{
+	{11025, 12000, 8000},  // MPEG 2.5
+	{},                    // reserved
+	{22050, 24000, 16000}, // MPEG 2
+	{44100, 48000, 32000}, // MPEG 1
+}
+
+// read_lame_info finds the Xing/Info frame at the start of an mp3 file and the
+// encoder delay and padding of its LAME extension. ok is false when the file
+// carries no such header.
+func read_lame_info(r io.ReadSeeker) (info lame_info, ok bool, err error) {
+	head := make([]byte, 10)
+	if _, err = io.ReadFull(r, head); err != nil {
+		return info, false, fmt.Errorf("Couldn't read mp3 header: %v", err)
+	}
+	skip := int64(0)
+	if string(head[0:3]) == "ID3" {
+		// syncsafe size of the tag, without its header and footer
+		size := int64(head[6])<<21 | int64(head[7])<<14 | int64(head[8])<<7 | int64(head[9])
+		skip = 10 + size
+		if head[5]&0x10 != 0 {
+			skip += 10
+		}
+	}
+	if _, err = r.Seek(skip, io.SeekStart); err != nil {
+		return info, false, err
+	}
+	buf := make([]byte, 4096)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return info, false, fmt.Errorf("Couldn't read mp3 header: %v", err)
+	}
+	buf = buf[:n]
+
+	pos := 0
+	for pos+4 <= len(buf) && !(buf[pos] == 0xff && buf[pos+1]&0xe0 == 0xe0) {
+		pos++
+	}
+	if pos+4 > len(buf) {
+		return info, false, nil
+	}
+	version := buf[pos+1] >> 3 & 3
+	layer := buf[pos+1] >> 1 & 3
+	rate_index := buf[pos+2] >> 2 & 3
+	mono := buf[pos+3]>>6 == 3
+	// only layer III carries the LAME header
+	if version == 1 || layer != 1 || rate_index == 3 {
+		return info, false, nil
+	}
+	info.sample_rate = mp3_sample_rates[version][rate_index]
+
+	row, column := 0, 1
+	samples_per_frame := 576
+	if version == 3 {
+		row = 1
+		samples_per_frame = 1152
+	}
+	if mono {
+		column = 0
+	}
+	side_info := mp3_side_info[row][column]
+	xing := pos + 4 + side_info
+	if xing+8 > len(buf) || (string(buf[xing:xing+4]) != "Xing" && string(buf[xing:xing+4]) != "Info") {
+		return info, false, nil
+	}
+	flags := binary.BigEndian.Uint32(buf[xing+4 : xing+8])
+	if flags&1 == 0 || xing+12 > len(buf) {
+		// without a frame count the trimmed length is unknown
+		return info, false, nil
+	}
+	info.samples = int(binary.BigEndian.Uint32(buf[xing+8:xing+12])) * samples_per_frame
+
+	lame := xing + 12
+	if flags&2 != 0 {
+		lame += 4
+	}
+	if flags&4 != 0 {
+		lame += 100
+	}
+	if flags&8 != 0 {
+		lame += 4
+	}
+	// encoder string, revision, lowpass, replay gain, flags and bitrate
+	// precede the 12 bit delay and padding
+	if lame+24 > len(buf) {
+		return info, false, nil
+	}
+	field := buf[lame+21 : lame+24]
+	info.delay = int(field[0])<<4 | int(field[1])>>4
+	info.padding = int(field[1]&0x0f)<<8 | int(field[2])
+	return info, true, nil
+}
+
+// FromFileGapless decodes file like From_file and, for mp3 files whose LAME
+// header records the encoder delay and padding, strips the priming and padding
+// samples so consecutive tracks of a gapless album join without a gap. frames
+// is the number of frames trimmed; ffmpeg versions that already honour the
+// header leave nothing to trim. Other formats decode unchanged.
+func FromFileGapless(file string, format string) (obj *AudioSegment, frames int, err error) {
+	obj, err = from_file(file, format)
+	if err != nil || format != "mp3" {
+		return obj, 0, err
+	}
+	f, err := fd_or_tempfile(file, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, ok, err := read_lame_info(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok || info.sample_rate != obj.frame_rate {
+		return obj, 0, nil
+	}
+
+	expected := info.samples - info.delay - info.padding
+	excess := obj.FrameCount() - expected
+	if expected <= 0 || excess <= 0 {
+		return obj, 0, nil
+	}
+	// padding left at the end is trimmed first, whatever remains is priming
+	end := info.padding - mp3_decoder_delay
+	if end < 0 {
+		end = 0
+	}
+	if end > excess {
+		end = excess
+	}
+	start := excess - end
+	return obj.SliceFrames(start, obj.FrameCount()-end), excess, nil
+}
@@ -0,0 +1,57 @@
+package AudioSegment
+
+import (
+	"bytes"
+	"testing"
+)
+
+// lame_header builds the start of an mp3 file: an empty ID3 tag and a layer
+// III frame holding an Info header with frame count and LAME delay/padding
+func lame_header(header [4]byte, side_info int, frames uint32, delay, padding int) []byte {
+	b := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 0}
+	b = append(b, header[:]...)
+	b = append(b, make([]byte, side_info)...)
+	b = append(b, 'I', 'n', 'f', 'o', 0, 0, 0, 1)
+	b = append(b, byte(frames>>24), byte(frames>>16), byte(frames>>8), byte(frames))
+	lame := make([]byte, 36)
+	copy(lame, "LAME3.100")
+	lame[21] = byte(delay >> 4)
+	lame[22] = byte(delay<<4) | byte(padding>>8)
+	lame[23] = byte(padding)
+	return append(b, lame...)
+}
+
+func TestReadLameInfo(t *testing.T) {
+	cases := []struct {
+		name      string
+		header    [4]byte
+		side_info int
+		rate      uint32
+		samples   int
+	}{
+		{"MPEG 1 stereo", [4]byte{0xff, 0xfb, 0x90, 0x00}, 32, 44100, 100 * 1152},
+		{"MPEG 1 mono", [4]byte{0xff, 0xfb, 0x90, 0xc0}, 17, 44100, 100 * 1152},
+		{"MPEG 2 stereo", [4]byte{0xff, 0xf3, 0x90, 0x00}, 17, 22050, 100 * 576},
+		{"MPEG 2 mono", [4]byte{0xff, 0xf3, 0x90, 0xc0}, 9, 22050, 100 * 576},
+		{"MPEG 2.5 mono", [4]byte{0xff, 0xe3, 0x90, 0xc0}, 9, 11025, 100 * 576},
+	}
+	for _, c := range cases {
+		data := lame_header(c.header, c.side_info, 100, 576, 1234)
+		info, ok, err := read_lame_info(bytes.NewReader(data))
+		if err != nil || !ok {
+			t.Errorf("%s: no LAME header found (ok %v, err %v)", c.name, ok, err)
+			continue
+		}
+		if info.sample_rate != c.rate || info.samples != c.samples || info.delay != 576 || info.padding != 1234 {
+			t.Errorf("%s: got %+v", c.name, info)
+		}
+	}
+}
+
+func TestReadLameInfoWithoutHeader(t *testing.T) {
+	data := lame_header([4]byte{0xff, 0xfb, 0x90, 0xc0}, 17, 100, 576, 1234)
+	copy(data[10+4+17:], "Nope")
+	if _, ok, err := read_lame_info(bytes.NewReader(data)); ok || err != nil {
+		t.Errorf("expected no header, got ok %v, err %v", ok, err)
+	}
+}
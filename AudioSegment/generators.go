@@ -0,0 +1,102 @@
+package AudioSegment
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Silent returns durationMs of mono 16-bit silence
+func Silent(durationMs int, frameRate uint32) *AudioSegment {
+	obj := &AudioSegment{channels: 1, frame_rate: frameRate, sample_width: 2, frame_width: 2}
+	data := obj.silent_data(obj.FrameCountMs(durationMs))
+	obj.set_data(&data)
+	return obj
+}
+
+// SineWave returns a full scale mono sine of freq Hz
+func SineWave(freq float64, durationMs int, frameRate uint32, sampleWidth uint16) *AudioSegment {
+	if sampleWidth < 1 || sampleWidth > 4 {
+		panic(fmt_width_error(sampleWidth))
+	}
+	obj := &AudioSegment{channels: 1, frame_rate: frameRate, sample_width: sampleWidth, frame_width: sampleWidth}
+	samples := make([]int32, obj.FrameCountMs(durationMs))
+	step := 2 * math.Pi * freq / float64(frameRate)
+	for i := range samples {
+		samples[i] = obj.clamp_float(math.Sin(step*float64(i)) * float64(obj.max_sample()))
+	}
+	data := obj.encode_samples(samples)
+	obj.set_data(&data)
+	return obj
+}
+
+type SweepMode int
+
+const (
+	// the frequency moves by the same Hz every second
+	LinearSweep SweepMode = iota
+	// the frequency moves by the same ratio every second
+	ExponentialSweep
+)
+
+// Chirp returns a full scale mono sine sweeping from startHz to endHz
+func Chirp(startHz, endHz float64, durationMs int, frameRate uint32, sampleWidth uint16, mode SweepMode) *AudioSegment {
+	if sampleWidth < 1 || sampleWidth > 4 {
+		panic(fmt_width_error(sampleWidth))
+	}
+	if startHz < 0 || endHz < 0 || (mode == ExponentialSweep && (startHz == 0 || endHz == 0)) {
+		errmsg := fmt.Sprintf("Invalid sweep %gHz-%gHz", startHz, endHz)
+		panic(errmsg)
+	}
+	obj := &AudioSegment{channels: 1, frame_rate: frameRate, sample_width: sampleWidth, frame_width: sampleWidth}
+	samples := make([]int32, obj.FrameCountMs(durationMs))
+	length := float64(durationMs) / 1000
+	ratio := endHz / startHz
+	for i := range samples {
+		t := float64(i) / float64(frameRate)
+		// the phase is the integral of the instantaneous frequency
+		var phase float64
+		if mode == ExponentialSweep && ratio != 1 {
+			phase = startHz * length / math.Log(ratio) * (math.Pow(ratio, t/length) - 1)
+		} else {
+			phase = startHz*t + (endHz-startHz)*t*t/(2*length)
+		}
+		samples[i] = obj.clamp_float(math.Sin(2*math.Pi*phase) * float64(obj.max_sample()))
+	}
+	data := obj.encode_samples(samples)
+	obj.set_data(&data)
+	return obj
+}
+
+var dtmf_frequencies = map[rune][2]float64{
+	'1': {697, 1209}, '2': {697, 1336}, '3': {697, 1477}, 'A': {697, 1633},
+	'4': {770, 1209}, '5': {770, 1336}, '6': {770, 1477}, 'B': {770, 1633},
+	'7': {852, 1209}, '8': {852, 1336}, '9': {852, 1477}, 'C': {852, 1633},
+	'*': {941, 1209}, '0': {941, 1336}, '#': {941, 1477}, 'D': {941, 1633},
+}
+
+// DTMF synthesizes the telephone keypad tones of digits, each toneMs long and
+// separated by gapMs of silence, as mono 16-bit audio
+func DTMF(digits string, toneMs, gapMs int, frameRate uint32) (*AudioSegment, error) {
+	if toneMs <= 0 || gapMs < 0 {
+		return nil, fmt.Errorf("Invalid DTMF timing (tone %dms, gap %dms)", toneMs, gapMs)
+	}
+	if len(digits) == 0 {
+		return Silent(0, frameRate), nil
+	}
+	builder := NewBuilder()
+	for i, digit := range strings.ToUpper(digits) {
+		pair, ok := dtmf_frequencies[digit]
+		if !ok {
+			return nil, fmt.Errorf("Invalid DTMF digit %q at %d", digit, i)
+		}
+		if i > 0 && gapMs > 0 {
+			builder.AddSilence(gapMs)
+		}
+		// each tone at half scale so the sum can't clip
+		low := SineWave(pair[0], toneMs, frameRate, 2).ApplyGain(ratio_to_db(0.5))
+		high := SineWave(pair[1], toneMs, frameRate, 2).ApplyGain(ratio_to_db(0.5))
+		builder.AddSegment(low.Overlay(high))
+	}
+	return builder.Build()
+}
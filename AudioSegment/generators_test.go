@@ -0,0 +1,86 @@
+package AudioSegment
+
+import (
+	"math"
+	"testing"
+)
+
+// goertzel returns the power of freq in x
+func goertzel(x []float64, freq, rate float64) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq/rate)
+	var s1, s2 float64
+	for _, v := range x {
+		s1, s2 = v+coeff*s1-s2, s1
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
+
+func TestDTMFFrequencies(t *testing.T) {
+	rows := []float64{697, 770, 852, 941}
+	columns := []float64{1209, 1336, 1477, 1633}
+	keypad := []string{"123A", "456B", "789C", "*0#D"}
+	for r, keys := range keypad {
+		for c, key := range keys {
+			seg, err := DTMF(string(key), 100, 0, 8000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			x := seg.GetFloatSamples()
+			strongest := func(freqs []float64) float64 {
+				best, power := 0.0, 0.0
+				for _, f := range freqs {
+					if p := goertzel(x, f, 8000); p > power {
+						best, power = f, p
+					}
+				}
+				return best
+			}
+			if row, column := strongest(rows), strongest(columns); row != rows[r] || column != columns[c] {
+				t.Errorf("%c: strongest tones %gHz and %gHz, want %gHz and %gHz", key, row, column, rows[r], columns[c])
+			}
+		}
+	}
+}
+
+// crossing_rate estimates the frequency of x from its zero crossings
+func crossing_rate(x []float64, rate float64) float64 {
+	crossings := 0
+	for i := 1; i < len(x); i++ {
+		if (x[i-1] < 0) != (x[i] < 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / 2 / (float64(len(x)) / rate)
+}
+
+func TestChirpFrequencies(t *testing.T) {
+	cases := []struct {
+		name               string
+		mode               SweepMode
+		start, middle, end float64
+	}{
+		{"linear", LinearSweep, 500, 2250, 4000},
+		{"exponential", ExponentialSweep, 500, math.Sqrt(500 * 4000), 4000},
+	}
+	for _, c := range cases {
+		seg := Chirp(500, 4000, 10000, 16000, 2, c.mode)
+		window := seg.FrameCountMs(50)
+		frames := seg.FrameCount()
+		x := seg.GetFloatSamples()
+		windows := []struct {
+			name string
+			at   int
+			want float64
+		}{
+			{"first", 0, c.start},
+			{"middle", frames/2 - window/2, c.middle},
+			{"last", frames - window, c.end},
+		}
+		for _, w := range windows {
+			f := crossing_rate(x[w.at:w.at+window], 16000)
+			if math.Abs(f-w.want) > w.want*0.03 {
+				t.Errorf("%s: %s window at %.0fHz, want %.0fHz", c.name, w.name, f, w.want)
+			}
+		}
+	}
+}
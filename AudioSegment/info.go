@@ -0,0 +1,61 @@
+package AudioSegment
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+func is_info_list(chunk WavChunk) bool {
+	return string(chunk.id) == "LIST" && len(chunk.data) >= 4 && string(chunk.data[0:4]) == "INFO"
+}
+
+// InfoTags returns the LIST/INFO tags of the source wav (IART, INAM, ICMT, ...)
+// by their four character id
+func (p *AudioSegment) InfoTags() map[string]string {
+	tags := make(map[string]string)
+	for _, chunk := range p.chunks {
+		if !is_info_list(chunk) {
+			continue
+		}
+		pos := 4
+		for pos+8 <= len(chunk.data) {
+			id := string(chunk.data[pos : pos+4])
+			size := int(binary.LittleEndian.Uint32(chunk.data[pos+4 : pos+8]))
+			if pos+8+size > len(chunk.data) {
+				break
+			}
+			text := chunk.data[pos+8 : pos+8+size]
+			if i := bytes.IndexByte(text, 0); i >= 0 {
+				text = text[:i]
+			}
+			tags[id] = string(text)
+			pos += 8 + size + size%2
+		}
+	}
+	return tags
+}
+
+// info_chunk builds the body of a LIST/INFO chunk holding tags, in id order
+func info_chunk(order binary.ByteOrder, tags map[string]string) ([]byte, error) {
+	ids := make([]string, 0, len(tags))
+	for id := range tags {
+		if len(id) != 4 {
+			return nil, fmt.Errorf("Invalid INFO tag id %q, ids are four characters", id)
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var buf bytes.Buffer
+	buf.WriteString("INFO")
+	for _, id := range ids {
+		// values are null terminated
+		text := append([]byte(tags[id]), 0)
+		if err := write_wav_chunk(&buf, order, []byte(id), text); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
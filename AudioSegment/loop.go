@@ -0,0 +1,106 @@
+package AudioSegment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+func (p *AudioSegment) has_loop() bool {
+	return p.loop_end > p.loop_start && p.loop_end <= p.FrameCount()
+}
+
+// slice_loop gives as, cut from frames [start, end) of p, the loop points of p
+// moved along, or none when the loop doesn't lie entirely inside the cut. The
+// markers inside the cut move along too.
+func (p *AudioSegment) slice_loop(as *AudioSegment, start, end int) *AudioSegment {
+	as.markers = p.slice_markers(start, end)
+	if !p.has_loop() || p.loop_start < start || p.loop_end > end {
+		as.loop_start, as.loop_end = 0, 0
+		return as
+	}
+	as.loop_start, as.loop_end = p.loop_start-start, p.loop_end-start
+	return as
+}
+
+// scale_loop gives as, p stretched or resampled to its length, the loop
+// points and markers of p scaled by the same ratio
+func (p *AudioSegment) scale_loop(as *AudioSegment) *AudioSegment {
+	as.markers = nil
+	if p.FrameCount() > 0 {
+		as.markers = p.scale_markers(float64(as.FrameCount()) / float64(p.FrameCount()))
+	}
+	if !p.has_loop() || as.FrameCount() == 0 {
+		as.loop_start, as.loop_end = 0, 0
+		return as
+	}
+	ratio := float64(as.FrameCount()) / float64(p.FrameCount())
+	as.loop_start = int(math.Round(float64(p.loop_start) * ratio))
+	as.loop_end = int(math.Round(float64(p.loop_end) * ratio))
+	if as.loop_end > as.FrameCount() {
+		as.loop_end = as.FrameCount()
+	}
+	return as
+}
+
+func (p *AudioSegment) SetLoopPoints(startMs, endMs int) *AudioSegment {
+	if startMs < 0 || endMs <= startMs || endMs > p.Len() {
+		errmsg := fmt.Sprintf("Invalid loop points %dms-%dms for a %dms AudioSegment", startMs, endMs, p.Len())
+		panic(errmsg)
+	}
+	as := p.view(p.data)
+	as.loop_start = p.FrameCountMs(startMs)
+	as.loop_end = p.FrameCountMs(endMs)
+	if as.loop_end > p.FrameCount() {
+		as.loop_end = p.FrameCount()
+	}
+	return as
+}
+
+// LoopN returns intro + loop region * times + outro. The loop points of the
+// result mark the first repetition of the region.
+func (p *AudioSegment) LoopN(times int) *AudioSegment {
+	if !p.has_loop() {
+		panic("AudioSegment has no loop points")
+	}
+	if times < 0 {
+		errmsg := fmt.Sprintf("Invalid loop count %d", times)
+		panic(errmsg)
+	}
+	frame_width := int(p.frame_width)
+	intro := (*p.data)[:p.loop_start*frame_width]
+	region := (*p.data)[p.loop_start*frame_width : p.loop_end*frame_width]
+	outro := (*p.data)[p.loop_end*frame_width:]
+
+	data := make([]byte, 0, len(intro)+len(region)*times+len(outro))
+	data = append(data, intro...)
+	for i := 0; i < times; i++ {
+		data = append(data, region...)
+	}
+	data = append(data, outro...)
+
+	as := p.spawn(&data)
+	// markers in the region stay on its first repetition
+	as.markers = p.moved_markers(p.loop_end, (times-1)*(p.loop_end-p.loop_start))
+	if times == 0 {
+		as.loop_start, as.loop_end = 0, 0
+	}
+	return as
+}
+
+// smpl_chunk encodes the loop points as a wav sampler chunk with a single
+// forward loop, or returns nil when the segment has no loop
+func (p *AudioSegment) smpl_chunk(order binary.ByteOrder) []byte {
+	if !p.has_loop() {
+		return nil
+	}
+	chunk := make([]byte, 36+24)
+	order.PutUint32(chunk[8:12], uint32(1e9/float64(p.frame_rate)))
+	order.PutUint32(chunk[12:16], 60)
+	order.PutUint32(chunk[28:32], 1)
+	loop := chunk[36:]
+	order.PutUint32(loop[8:12], uint32(p.loop_start))
+	// the end point is the last frame played, inclusive
+	order.PutUint32(loop[12:16], uint32(p.loop_end-1))
+	return chunk
+}
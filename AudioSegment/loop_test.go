@@ -0,0 +1,75 @@
+package AudioSegment
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoopPointsFollowDerivedSegments(t *testing.T) {
+	seg := SineWave(440, 1000, 8000, 2).SetLoopPoints(200, 400)
+	cases := []struct {
+		name       string
+		out        *AudioSegment
+		start, end int
+	}{
+		{"SliceFrames before the loop", seg.SliceFrames(800, 8000), 800, 2400},
+		{"SliceFrames cutting the loop", seg.SliceFrames(2000, 8000), 0, 0},
+		{"Slice in bytes", seg.Slice(1600, 16000), 800, 2400},
+		{"Slice off frame boundaries", seg.Slice(1601, 15999), 0, 0},
+		{"SplitN piece holding the loop", seg.SplitN(2)[0], 1600, 3200},
+		{"SplitN piece after the loop", seg.SplitN(2)[1], 0, 0},
+		{"SetFrameRate", seg.SetFrameRate(16000), 3200, 6400},
+		{"TimeStretch", seg.TimeStretch(2), 3200, 6400},
+		{"SnapToGrid trim", seg.SnapToGrid(60, 1).SliceFrames(400, 8000), 1200, 2800},
+		{"CrossfadeJoin after the loop", seg.CrossfadeJoin(500, 600, 20), 1600, 3200},
+		{"CrossfadeJoin across the loop", seg.CrossfadeJoin(300, 600, 20), 0, 0},
+	}
+	for _, c := range cases {
+		if c.out.loop_start != c.start || c.out.loop_end != c.end {
+			t.Errorf("%s: loop %d-%d, want %d-%d", c.name, c.out.loop_start, c.out.loop_end, c.start, c.end)
+		}
+		if c.end > 0 && !c.out.has_loop() {
+			t.Errorf("%s: loop %d-%d doesn't fit %d frames", c.name, c.start, c.end, c.out.FrameCount())
+		}
+	}
+}
+
+func TestSmplChunkAfterSlice(t *testing.T) {
+	// a stale loop that still fits the shorter piece must not be written
+	seg := SineWave(440, 1000, 8000, 2).SetLoopPoints(100, 200).SliceFrames(1000, 8000)
+	if seg.smpl_chunk(nil) != nil {
+		t.Errorf("smpl chunk written for loop %d-%d", seg.loop_start, seg.loop_end)
+	}
+}
+
+func TestPreservedSmplChunkAfterSlice(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "loop.wav")
+	SineWave(440, 1000, 8000, 2).SetLoopPoints(100, 200).Export(src, "wav")
+	seg := From_file(src, "wav")
+	cases := []struct {
+		name       string
+		out        *AudioSegment
+		start, end int
+	}{
+		{"loop kept", seg.SliceFrames(400, 8000), 400, 1200},
+		{"loop cut away", seg.SliceFrames(2000, 8000), 0, 0},
+	}
+	for _, c := range cases {
+		out := filepath.Join(dir, "out.wav")
+		c.out.ExportWithOptions(out, "wav", ExportOptions{PreserveChunks: true})
+		back := From_file(out, "wav")
+		if back.loop_start != c.start || back.loop_end != c.end {
+			t.Errorf("%s: reloaded loop %d-%d, want %d-%d", c.name, back.loop_start, back.loop_end, c.start, c.end)
+		}
+		smpl := 0
+		for _, chunk := range back.chunks {
+			if string(chunk.id) == "smpl" {
+				smpl++
+			}
+		}
+		if smpl > 1 || (smpl == 1) != (c.end > 0) {
+			t.Errorf("%s: %d smpl chunks written", c.name, smpl)
+		}
+	}
+}
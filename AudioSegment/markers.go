@@ -0,0 +1,214 @@
+package AudioSegment
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+type Marker struct {
+	Label      string
+	PositionMs int
+	frame      int
+	// cue point id, for the markers read from a cue chunk
+	cue_id uint32
+	cue    bool
+}
+
+func (p *AudioSegment) find_chunk(id string) []byte {
+	for _, chunk := range p.chunks {
+		if string(chunk.id) == id {
+			return chunk.data
+		}
+	}
+	return nil
+}
+
+// cue_labels reads the labl entries of the LIST/adtl chunk, by cue point id
+func (p *AudioSegment) cue_labels() map[uint32]string {
+	labels := make(map[uint32]string)
+	for _, chunk := range p.chunks {
+		if string(chunk.id) != "LIST" || len(chunk.data) < 4 || string(chunk.data[0:4]) != "adtl" {
+			continue
+		}
+		pos := 4
+		for pos+8 <= len(chunk.data) {
+			sub_id := string(chunk.data[pos : pos+4])
+			size := int(binary.LittleEndian.Uint32(chunk.data[pos+4 : pos+8]))
+			if pos+8+size > len(chunk.data) {
+				break
+			}
+			body := chunk.data[pos+8 : pos+8+size]
+			if sub_id == "labl" && size >= 4 {
+				text := body[4:]
+				if i := bytes.IndexByte(text, 0); i >= 0 {
+					text = text[:i]
+				}
+				labels[binary.LittleEndian.Uint32(body[0:4])] = string(text)
+			}
+			pos += 8 + size + size%2
+		}
+	}
+	return labels
+}
+
+func (p *AudioSegment) frame_to_ms(frame int) int {
+	return int(math.Round(1000 * float64(frame) / float64(p.frame_rate)))
+}
+
+// smpl_loops returns the start and end (exclusive) frame and cue id of the
+// loops in the smpl chunk
+func smpl_loops(chunk []byte) [][3]uint32 {
+	if len(chunk) < 36 {
+		return nil
+	}
+	// the count comes from the file, never trust it beyond the chunk size
+	count := int(binary.LittleEndian.Uint32(chunk[28:32]))
+	if fits := (len(chunk) - 36) / 24; count > fits {
+		count = fits
+	}
+	loops := make([][3]uint32, 0, count)
+	for i := 0; i < count; i++ {
+		loop := chunk[36+i*24:]
+		id := binary.LittleEndian.Uint32(loop[0:4])
+		start := binary.LittleEndian.Uint32(loop[8:12])
+		end := binary.LittleEndian.Uint32(loop[12:16]) + 1
+		loops = append(loops, [3]uint32{start, end, id})
+	}
+	return loops
+}
+
+// wav_markers reads the cue points and the smpl loop boundaries of the wav
+// chunks, ordered by position
+func (p *AudioSegment) wav_markers() []Marker {
+	labels := p.cue_labels()
+	markers := make([]Marker, 0)
+
+	if cue := p.find_chunk("cue "); len(cue) >= 4 {
+		count := int(binary.LittleEndian.Uint32(cue[0:4]))
+		for i := 0; i < count && 4+(i+1)*24 <= len(cue); i++ {
+			point := cue[4+i*24:]
+			id := binary.LittleEndian.Uint32(point[0:4])
+			frame := int(binary.LittleEndian.Uint32(point[20:24]))
+			label, ok := labels[id]
+			if !ok {
+				label = fmt.Sprintf("cue %d", id)
+			}
+			markers = append(markers, Marker{Label: label, frame: frame, cue_id: id, cue: true})
+		}
+	}
+
+	for i, loop := range smpl_loops(p.find_chunk("smpl")) {
+		label, ok := labels[loop[2]]
+		if !ok {
+			label = fmt.Sprintf("loop %d", i)
+		}
+		markers = append(markers,
+			Marker{Label: label + " start", frame: int(loop[0])},
+			Marker{Label: label + " end", frame: int(loop[1])})
+	}
+
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].frame < markers[j].frame })
+	return markers
+}
+
+// cue_chunk encodes the markers read from cue points at their current
+// positions, or returns nil when there are none
+func (p *AudioSegment) cue_chunk(order binary.ByteOrder) []byte {
+	var cues []Marker
+	for _, marker := range p.Markers() {
+		if marker.cue {
+			cues = append(cues, marker)
+		}
+	}
+	if len(cues) == 0 {
+		return nil
+	}
+	chunk := make([]byte, 4+24*len(cues))
+	order.PutUint32(chunk[0:4], uint32(len(cues)))
+	for i, marker := range cues {
+		point := chunk[4+i*24:]
+		order.PutUint32(point[0:4], marker.cue_id)
+		order.PutUint32(point[4:8], uint32(marker.frame))
+		copy(point[8:12], "data")
+		order.PutUint32(point[20:24], uint32(marker.frame))
+	}
+	return chunk
+}
+
+// slice_markers returns the markers within frames [start, end] of the
+// segment, moved to the start of the cut
+func (p *AudioSegment) slice_markers(start, end int) []Marker {
+	var markers []Marker
+	for _, marker := range p.markers {
+		if marker.frame >= start && marker.frame <= end {
+			marker.frame -= start
+			markers = append(markers, marker)
+		}
+	}
+	return markers
+}
+
+// scale_markers returns the markers with their frames scaled by ratio, for
+// stretched or resampled audio
+func (p *AudioSegment) scale_markers(ratio float64) []Marker {
+	var markers []Marker
+	for _, marker := range p.markers {
+		marker.frame = int(math.Round(float64(marker.frame) * ratio))
+		markers = append(markers, marker)
+	}
+	return markers
+}
+
+// moved_markers returns the markers with those at or after frame at moved by
+// frames, which audio inserted at at pushes back. A negative frames removes
+// the audio before at, and the markers in it.
+func (p *AudioSegment) moved_markers(at, frames int) []Marker {
+	var markers []Marker
+	for _, marker := range p.markers {
+		if marker.frame >= at {
+			marker.frame += frames
+		} else if marker.frame >= at+frames {
+			continue
+		}
+		markers = append(markers, marker)
+	}
+	return markers
+}
+
+// Markers lists the cue points and the smpl loop boundaries read from the
+// source wav, ordered by position. They move with the audio through slices,
+// resampling, stretching and joins; those cut away are dropped.
+func (p *AudioSegment) Markers() []Marker {
+	markers := make([]Marker, 0, len(p.markers))
+	for _, marker := range p.markers {
+		if marker.frame > p.FrameCount() {
+			continue
+		}
+		marker.PositionMs = p.frame_to_ms(marker.frame)
+		markers = append(markers, marker)
+	}
+	// joined segments list the markers of each in turn
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].frame < markers[j].frame })
+	return markers
+}
+
+// SplitAtMarkers cuts the segment at every marker that falls inside it
+func (p *AudioSegment) SplitAtMarkers() []*AudioSegment {
+	frames := p.FrameCount()
+	frame_width := int(p.frame_width)
+	pieces := make([]*AudioSegment, 0)
+	start := 0
+	for _, marker := range p.Markers() {
+		if marker.frame <= start || marker.frame >= frames {
+			continue
+		}
+		data := (*p.data)[start*frame_width : marker.frame*frame_width]
+		pieces = append(pieces, p.slice_loop(p.view(&data), start, marker.frame))
+		start = marker.frame
+	}
+	data := (*p.data)[start*frame_width:]
+	return append(pieces, p.slice_loop(p.view(&data), start, frames))
+}
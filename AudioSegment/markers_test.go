@@ -0,0 +1,165 @@
+package AudioSegment
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSmplLoopsCount(t *testing.T) {
+	cases := []struct {
+		name  string
+		count uint32
+		loops int
+		want  int
+	}{
+		{"exact", 2, 2, 2},
+		{"fewer than stored", 1, 2, 1},
+		{"more than stored", 5, 2, 2},
+		{"huge count", 0xffffffff, 1, 1},
+	}
+	for _, c := range cases {
+		chunk := make([]byte, 36+24*c.loops)
+		binary.LittleEndian.PutUint32(chunk[28:32], c.count)
+		for i := 0; i < c.loops; i++ {
+			loop := chunk[36+i*24:]
+			binary.LittleEndian.PutUint32(loop[8:12], uint32(100*i))
+			binary.LittleEndian.PutUint32(loop[12:16], uint32(100*i+49))
+		}
+		loops := smpl_loops(chunk)
+		if len(loops) != c.want {
+			t.Errorf("%s: got %d loops, want %d", c.name, len(loops), c.want)
+			continue
+		}
+		for i, loop := range loops {
+			if loop[0] != uint32(100*i) || loop[1] != uint32(100*i+50) {
+				t.Errorf("%s: loop %d spans %d-%d", c.name, i, loop[0], loop[1])
+			}
+		}
+	}
+}
+
+// wav_with_cues writes seg as a wav file with a cue point at each frame
+func wav_with_cues(t *testing.T, seg *AudioSegment, frames []int) string {
+	fmt_chunk, err := seg.fmt_chunk(binary.LittleEndian, WavFormatPCM, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cue := make([]byte, 4+24*len(frames))
+	binary.LittleEndian.PutUint32(cue[0:4], uint32(len(frames)))
+	for i, frame := range frames {
+		point := cue[4+i*24:]
+		binary.LittleEndian.PutUint32(point[0:4], uint32(i+1))
+		copy(point[8:12], "data")
+		binary.LittleEndian.PutUint32(point[20:24], uint32(frame))
+	}
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+	for _, chunk := range []WavChunk{{[]byte("fmt "), fmt_chunk}, {[]byte("cue "), cue}, {[]byte("data"), *seg.data}} {
+		if err := write_wav_chunk(&body, binary.LittleEndian, chunk.id, chunk.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	header := make([]byte, 8)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(body.Len()))
+	file := filepath.Join(t.TempDir(), "cues.wav")
+	if err := ioutil.WriteFile(file, append(header, body.Bytes()...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func marker_frames(seg *AudioSegment) []int {
+	frames := make([]int, 0)
+	for _, marker := range seg.Markers() {
+		frames = append(frames, marker.frame)
+	}
+	return frames
+}
+
+func TestMarkersFollowDerivedSegments(t *testing.T) {
+	src := SineWave(440, 1000, 8000, 2)
+	seg := From_file(wav_with_cues(t, src, []int{1000, 3000, 6000}), "wav")
+	joined, err := src.SliceFrames(0, 4000).AppendCrossfade(seg, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		out  *AudioSegment
+		want []int
+	}{
+		{"loaded", seg, []int{1000, 3000, 6000}},
+		{"SliceFrames", seg.SliceFrames(2000, 8000), []int{1000, 4000}},
+		{"Slice in bytes", seg.Slice(4000, 16000), []int{1000, 4000}},
+		{"Slice off frame boundaries", seg.Slice(4001, 15999), []int{}},
+		{"SetFrameRate", seg.SetFrameRate(16000), []int{2000, 6000, 12000}},
+		{"TimeStretch", seg.TimeStretch(0.5), []int{500, 1500, 3000}},
+		{"PrependSilence", seg.PrependSilence(100), []int{1800, 3800, 6800}},
+		{"Prepend", seg.Prepend(src.SliceFrames(0, 4000)), []int{5000, 7000, 10000}},
+		{"Insert", seg.Insert(src.SliceFrames(0, 800), 250), []int{1000, 3800, 6800}},
+		{"AppendCrossfade", joined, []int{4200, 6200, 9200}},
+		{"CrossfadeJoin", seg.CrossfadeJoin(500, 600, 20), []int{1000, 3000, 5200}},
+	}
+	for _, c := range cases {
+		got := marker_frames(c.out)
+		if len(got) != len(c.want) {
+			t.Errorf("%s: markers at %v, want %v", c.name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: markers at %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSplitAtMarkersAfterSlice(t *testing.T) {
+	src := SineWave(440, 1000, 8000, 2)
+	seg := From_file(wav_with_cues(t, src, []int{1000, 3000, 6000}), "wav").SliceFrames(2000, 8000)
+	want := []int{1000, 3000, 2000}
+	pieces := seg.SplitAtMarkers()
+	if len(pieces) != len(want) {
+		t.Fatalf("%d pieces, want %d", len(pieces), len(want))
+	}
+	for i, piece := range pieces {
+		if piece.FrameCount() != want[i] {
+			t.Errorf("piece %d has %d frames, want %d", i, piece.FrameCount(), want[i])
+		}
+	}
+}
+
+func TestPreservedCuesAfterSlice(t *testing.T) {
+	src := SineWave(440, 1000, 8000, 2)
+	seg := From_file(wav_with_cues(t, src, []int{1000, 3000, 6000}), "wav")
+	cases := []struct {
+		name string
+		out  *AudioSegment
+		want []int
+	}{
+		{"unchanged", seg, []int{1000, 3000, 6000}},
+		{"SliceFrames", seg.SliceFrames(2000, 8000), []int{1000, 4000}},
+		{"SetFrameRate", seg.SetFrameRate(16000), []int{2000, 6000, 12000}},
+		{"no cue left", seg.SliceFrames(7000, 8000), []int{}},
+	}
+	for _, c := range cases {
+		file := filepath.Join(t.TempDir(), "out.wav")
+		c.out.ExportWithOptions(file, "wav", ExportOptions{PreserveChunks: true})
+		got := marker_frames(From_file(file, "wav"))
+		if len(got) != len(c.want) {
+			t.Errorf("%s: reloaded markers at %v, want %v", c.name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: reloaded markers at %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package AudioSegment
+
+import (
+	"fmt"
+	"math"
+)
+
+// MixNormalized sums segs sample by sample. When the sum would clip, the whole
+// mix is scaled down so its peak sits at -0.1dBFS; the applied scale factor is
+// returned alongside (1 when no scaling was needed).
+func MixNormalized(segs []*AudioSegment) (*AudioSegment, float64) {
+	if len(segs) == 0 {
+		panic("No AudioSegments to mix")
+	}
+	first := segs[0]
+	frames := 0
+	for i, seg := range segs {
+		if err := first.check_compatible(seg); err != nil {
+			errmsg := fmt.Sprintf("Can't mix AudioSegment %d: %v", i, err)
+			panic(errmsg)
+		}
+		if seg.FrameCount() > frames {
+			frames = seg.FrameCount()
+		}
+	}
+
+	sums := make([]int64, frames*int(first.channels))
+	for _, seg := range segs {
+		seg_samples := seg.samples()
+		for i, v := range seg_samples {
+			sums[i] += int64(v)
+		}
+		release_samples(seg_samples)
+	}
+	var peak int64
+	for _, v := range sums {
+		if v < 0 {
+			v = -v
+		}
+		if v > peak {
+			peak = v
+		}
+	}
+
+	scale := 1.0
+	if peak > first.max_sample() {
+		scale = first.max_possible_amplitude() * db_to_ratio(-0.1) / float64(peak)
+	}
+	samples := make([]int32, len(sums))
+	for i, v := range sums {
+		samples[i] = first.saturate_float(math.Round(float64(v) * scale))
+	}
+	return first.spawn_samples(samples), scale
+}
+
+// OverlayAt mixes seg into p at every position in positionsMs in a single
+// pass. Copies running past the end are cut, negative positions are skipped.
+func (p *AudioSegment) OverlayAt(seg *AudioSegment, positionsMs []int) *AudioSegment {
+	if err := p.check_compatible(seg); err != nil {
+		panic(err)
+	}
+	sums := make([]int64, p.FrameCount()*int(p.channels))
+	base := p.samples()
+	defer release_samples(base)
+	for i, v := range base {
+		sums[i] = int64(v)
+	}
+	effect := seg.samples()
+	defer release_samples(effect)
+	channels := int(p.channels)
+	for _, ms := range positionsMs {
+		if ms < 0 {
+			continue
+		}
+		offset := p.FrameCountMs(ms) * channels
+		for i := 0; i < len(effect) && offset+i < len(sums); i++ {
+			sums[offset+i] += int64(effect[i])
+		}
+	}
+	samples := make([]int32, len(sums))
+	for i, v := range sums {
+		samples[i] = p.saturate(v)
+	}
+	return p.spawn_samples(samples)
+}
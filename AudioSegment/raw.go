@@ -0,0 +1,75 @@
+package AudioSegment
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RawPCMOptions describes the layout of headerless PCM
+type RawPCMOptions struct {
+	Channels  uint16
+	FrameRate uint32
+	// bytes per sample, 4 or 8 with Float
+	SampleWidth uint16
+	// two's complement samples, otherwise offset binary (unsigned)
+	Signed    bool
+	BigEndian bool
+	// IEEE float samples, converted to 32-bit integers
+	Float bool
+}
+
+// FromRawPCM interprets data as interleaved samples laid out as opts says
+func FromRawPCM(data []byte, opts RawPCMOptions) (*AudioSegment, error) {
+	width := int(opts.SampleWidth)
+	if opts.Channels == 0 || opts.FrameRate == 0 {
+		return nil, fmt.Errorf("Invalid raw PCM format %d channels, %dHz", opts.Channels, opts.FrameRate)
+	}
+	if opts.Float && width != 4 && width != 8 {
+		return nil, fmt.Errorf("Unsupported float sample width %d bytes", width)
+	} else if !opts.Float && (width < 1 || width > 4) {
+		return nil, errors.New(fmt_width_error(opts.SampleWidth))
+	}
+	frame_width := int(opts.Channels) * width
+	if len(data)%frame_width != 0 {
+		return nil, fmt.Errorf("%d bytes of audio can't be split into frames of %d bytes", len(data), frame_width)
+	}
+
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	if opts.BigEndian {
+		for i := 0; i+width <= len(buf); i += width {
+			for a, b := i, i+width-1; a < b; a, b = a+1, b-1 {
+				buf[a], buf[b] = buf[b], buf[a]
+			}
+		}
+	}
+	obj := &AudioSegment{
+		channels:     opts.Channels,
+		frame_rate:   opts.FrameRate,
+		sample_width: opts.SampleWidth,
+		frame_width:  uint16(frame_width),
+	}
+	obj.set_data(&buf)
+	if opts.Float {
+		if err := obj.convert_float_data(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+
+	bits := uint(width * 8)
+	for i := 0; i+width <= len(buf); i += width {
+		var u uint32
+		for b := width - 1; b >= 0; b-- {
+			u = u<<8 | uint32(buf[i+b])
+		}
+		var v int32
+		if opts.Signed {
+			v = int32(u<<(32-bits)) >> (32 - bits)
+		} else {
+			v = int32(int64(u) - int64(1)<<(bits-1))
+		}
+		encode_sample(buf[i:], opts.SampleWidth, v)
+	}
+	return obj, nil
+}
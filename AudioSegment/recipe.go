@@ -0,0 +1,133 @@
+package AudioSegment
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// RecipeStep is one transform of a Recipe, Op names the AudioSegment method
+// and Params are its arguments in order
+type RecipeStep struct {
+	Op     string    `json:"op"`
+	Params []float64 `json:"params,omitempty"`
+}
+
+// Recipe is a serializable chain of transforms, such as a mastering preset,
+// that can be saved as JSON and applied to any segment with ApplyRecipe
+type Recipe struct {
+	Steps []RecipeStep `json:"steps"`
+}
+
+type recipe_op struct {
+	params int
+	apply  func(p *AudioSegment, v []float64) *AudioSegment
+}
+
+func recipe_int(v float64) int {
+	return int(math.Round(v))
+}
+
+// recipe_ops lists the transforms a recipe can hold, those taking only numbers
+var recipe_ops = map[string]recipe_op{
+	"ApplyGain":      {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.ApplyGain(v[0]) }},
+	"ApplyGainRatio": {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.ApplyGainRatio(v[0]) }},
+	"ApplyGainRange": {3, func(p *AudioSegment, v []float64) *AudioSegment {
+		return p.ApplyGainRange(v[0], recipe_int(v[1]), recipe_int(v[2]))
+	}},
+	"Normalize":          {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.Normalize(v[0]) }},
+	"NormalizeAWeighted": {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.NormalizeAWeighted(v[0]) }},
+	"FadeIn":             {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.FadeIn(recipe_int(v[0])) }},
+	"FadeOut":            {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.FadeOut(recipe_int(v[0])) }},
+	"SliceClean": {2, func(p *AudioSegment, v []float64) *AudioSegment {
+		return p.SliceClean(recipe_int(v[0]), recipe_int(v[1]))
+	}},
+	"PrependSilence": {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.PrependSilence(recipe_int(v[0])) }},
+	"StripSilence":   {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.StripSilence(v[0]) }},
+	"LoopN":          {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.LoopN(recipe_int(v[0])) }},
+	"SnapToGrid":     {2, func(p *AudioSegment, v []float64) *AudioSegment { return p.SnapToGrid(v[0], recipe_int(v[1])) }},
+	"SetFrameRate":   {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.SetFrameRate(uint32(recipe_int(v[0]))) }},
+	"SetChannels":    {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.SetChannels(uint16(recipe_int(v[0]))) }},
+	"SetSampleWidth": {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.SetSampleWidth(uint16(recipe_int(v[0]))) }},
+	"TimeStretch":    {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.TimeStretch(v[0]) }},
+	"PitchShift":     {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.PitchShift(v[0]) }},
+	"Reverb":         {3, func(p *AudioSegment, v []float64) *AudioSegment { return p.Reverb(recipe_int(v[0]), v[1], v[2]) }},
+	"Echo": {3, func(p *AudioSegment, v []float64) *AudioSegment {
+		return p.Echo(recipe_int(v[0]), v[1], recipe_int(v[2]))
+	}},
+	"NoiseGate":        {4, func(p *AudioSegment, v []float64) *AudioSegment { return p.NoiseGate(v[0], v[1], v[2], v[3]) }},
+	"Tremolo":          {2, func(p *AudioSegment, v []float64) *AudioSegment { return p.Tremolo(v[0], v[1]) }},
+	"DeEss":            {3, func(p *AudioSegment, v []float64) *AudioSegment { return p.DeEss(v[0], v[1], v[2]) }},
+	"DeClick":          {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.DeClick(v[0]) }},
+	"PeakingEQ":        {3, func(p *AudioSegment, v []float64) *AudioSegment { return p.PeakingEQ(v[0], v[1], v[2]) }},
+	"LowShelf":         {3, func(p *AudioSegment, v []float64) *AudioSegment { return p.LowShelf(v[0], v[1], v[2]) }},
+	"HighShelf":        {3, func(p *AudioSegment, v []float64) *AudioSegment { return p.HighShelf(v[0], v[1], v[2]) }},
+	"BitCrush":         {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.BitCrush(uint16(recipe_int(v[0]))) }},
+	"SampleRateReduce": {1, func(p *AudioSegment, v []float64) *AudioSegment { return p.SampleRateReduce(recipe_int(v[0])) }},
+	"Limit":            {2, func(p *AudioSegment, v []float64) *AudioSegment { return p.Limit(v[0], v[1]) }},
+	"RemoveDCOffset":   {0, func(p *AudioSegment, v []float64) *AudioSegment { return p.RemoveDCOffset() }},
+}
+
+func (s RecipeStep) check() error {
+	op, ok := recipe_ops[s.Op]
+	if !ok {
+		return fmt.Errorf("Unknown transform %q", s.Op)
+	}
+	if len(s.Params) != op.params {
+		return fmt.Errorf("%s takes %d parameters, got %d", s.Op, op.params, len(s.Params))
+	}
+	return nil
+}
+
+func NewRecipe() *Recipe {
+	return &Recipe{Steps: make([]RecipeStep, 0)}
+}
+
+// Add appends the transform named op with its parameters, integer parameters
+// are rounded when applied
+func (r *Recipe) Add(op string, params ...float64) *Recipe {
+	step := RecipeStep{Op: op, Params: params}
+	if err := step.check(); err != nil {
+		panic(err)
+	}
+	r.Steps = append(r.Steps, step)
+	return r
+}
+
+func (r *Recipe) ToJSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// RecipeFromJSON parses a recipe written by ToJSON and checks every step
+// against the transforms it can name
+func RecipeFromJSON(data []byte) (*Recipe, error) {
+	r := NewRecipe()
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("Invalid recipe: %v", err)
+	}
+	for i, step := range r.Steps {
+		if err := step.check(); err != nil {
+			return nil, fmt.Errorf("Recipe step %d: %v", i, err)
+		}
+	}
+	return r, nil
+}
+
+// ApplyRecipe runs the steps of r on the segment in order. Every step is
+// checked before the first one runs.
+func (p *AudioSegment) ApplyRecipe(r Recipe) *AudioSegment {
+	for i, step := range r.Steps {
+		if err := step.check(); err != nil {
+			errmsg := fmt.Sprintf("Recipe step %d: %v", i, err)
+			panic(errmsg)
+		}
+	}
+	if len(r.Steps) == 0 {
+		return p.apply_ratio(1)
+	}
+	out := p
+	for _, step := range r.Steps {
+		out = recipe_ops[step.Op].apply(out, step.Params)
+	}
+	return out
+}
@@ -0,0 +1,35 @@
+package AudioSegment
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSetFrameRateKeepsDuration(t *testing.T) {
+	cases := []struct {
+		from, to uint32
+		ms       int
+	}{
+		{48000, 44100, 1000},
+		{48000, 44100, 1234},
+		{44100, 16000, 1000},
+		{44100, 16000, 777},
+	}
+	for _, c := range cases {
+		src := SineWave(440, c.ms, c.from, 2)
+		out := src.SetFrameRate(c.to)
+		want := int(math.Round(float64(src.FrameCount()) * float64(c.to) / float64(c.from)))
+		if out.FrameCount() != want {
+			t.Errorf("%d→%dHz, %dms: %d frames, want %d", c.from, c.to, c.ms, out.FrameCount(), want)
+		}
+		if out.Len() != src.Len() {
+			t.Errorf("%d→%dHz, %dms: Len is %d, want %d", c.from, c.to, c.ms, out.Len(), src.Len())
+		}
+		// the output can only be off by the rounding of its last frame
+		frame := time.Second / time.Duration(c.to)
+		if diff := out.Duration() - src.Duration(); diff > frame || diff < -frame {
+			t.Errorf("%d→%dHz, %dms: Duration is %v, want %v", c.from, c.to, c.ms, out.Duration(), src.Duration())
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package AudioSegment
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+func decode_sample(b []byte, width uint16) int32 {
+	switch width {
+	case 1:
+		// 8-bit wav samples are unsigned
+		return int32(b[0]) - 128
+	case 2:
+		return int32(int16(uint16(b[0]) | uint16(b[1])<<8))
+	case 3:
+		return int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+	case 4:
+		return int32(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+	}
+	panic(fmt_width_error(width))
+}
+
+func encode_sample(b []byte, width uint16, v int32) {
+	switch width {
+	case 1:
+		b[0] = byte(v + 128)
+	case 2:
+		b[0], b[1] = byte(v), byte(v>>8)
+	case 3:
+		b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+	case 4:
+		b[0], b[1], b[2], b[3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+	default:
+		panic(fmt_width_error(width))
+	}
+}
+
+func fmt_width_error(width uint16) string {
+	return fmt.Sprintf("Unsupported sample width %d bytes", width)
+}
+
+func (p *AudioSegment) max_possible_amplitude() float64 {
+	return float64(int64(1) << (p.sample_width*8 - 1))
+}
+
+func (p *AudioSegment) min_sample() int64 {
+	return -(int64(1) << (p.sample_width*8 - 1))
+}
+
+func (p *AudioSegment) max_sample() int64 {
+	return int64(1)<<(p.sample_width*8-1) - 1
+}
+
+// ClipMode is how sample arithmetic handles results outside the sample range
+type ClipMode int32
+
+const (
+	// saturate to the nearest representable value
+	ClipClamp ClipMode = iota
+	// keep the low bits, like integer overflow
+	ClipWrap
+	// panic with ErrClipped
+	ClipError
+)
+
+// ErrClipped is the panic of transforms in ClipError mode. The functions
+// already returning an error, such as the exports and ProcessDir, return it
+// instead.
+var ErrClipped = errors.New("Sample value out of range")
+
+// recover_clipped turns an ErrClipped panic into *err, deferred by the
+// exported functions returning errors. Other panics go on.
+func recover_clipped(err *error) {
+	if r := recover(); r != nil {
+		if r != ErrClipped {
+			panic(r)
+		}
+		*err = ErrClipped
+	}
+}
+
+var clip_mode int32 = int32(ClipClamp)
+
+func SetClipMode(mode ClipMode) {
+	atomic.StoreInt32(&clip_mode, int32(mode))
+}
+
+func GetClipMode() ClipMode {
+	return ClipMode(atomic.LoadInt32(&clip_mode))
+}
+
+// saturate brings the result of sample arithmetic (gain, mixing, effects)
+// into the sample range following the clip mode
+func (p *AudioSegment) saturate(v int64) int32 {
+	if v <= p.max_sample() && v >= p.min_sample() {
+		return int32(v)
+	}
+	switch GetClipMode() {
+	case ClipWrap:
+		shift := 64 - uint(p.sample_width)*8
+		return int32(v << shift >> shift)
+	case ClipError:
+		panic(ErrClipped)
+	}
+	return p.clamp(v)
+}
+
+// clamp saturates v whatever the clip mode, for format conversion and
+// quantization where a full scale sample may round one step over the range
+func (p *AudioSegment) clamp(v int64) int32 {
+	if v > p.max_sample() {
+		return int32(p.max_sample())
+	}
+	if v < p.min_sample() {
+		return int32(p.min_sample())
+	}
+	return int32(v)
+}
+
+// round_sample rounds v, kept representable for saturate and clamp
+func round_sample(v float64) int64 {
+	v = math.Round(v)
+	if v > math.MaxInt32*2 {
+		v = math.MaxInt32 * 2
+	} else if v < math.MinInt32*2 {
+		v = math.MinInt32 * 2
+	}
+	return int64(v)
+}
+
+func (p *AudioSegment) saturate_float(v float64) int32 {
+	return p.saturate(round_sample(v))
+}
+
+func (p *AudioSegment) clamp_float(v float64) int32 {
+	return p.clamp(round_sample(v))
+}
+
+// buffers over max_pooled_samples are left to the garbage collector, so one
+// long file doesn't keep its scratch memory alive in the pool
+const max_pooled_samples = 1 << 20
+
+var (
+	buffer_pool_enabled int32
+	sample_buffers      sync.Pool
+)
+
+// SetBufferPool makes the transforms reuse their scratch sample buffers
+// through a sync.Pool instead of allocating new ones every call, which cuts
+// the garbage of servers processing many short clips. Results are the same
+// either way.
+func SetBufferPool(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&buffer_pool_enabled, v)
+}
+
+// sample_buffer returns a scratch slice of n samples with undefined contents
+func sample_buffer(n int) []int32 {
+	if atomic.LoadInt32(&buffer_pool_enabled) == 1 && n <= max_pooled_samples {
+		if buf, ok := sample_buffers.Get().(*[]int32); ok {
+			if cap(*buf) >= n {
+				return (*buf)[:n]
+			}
+			// too small for this call, but still good for a shorter one
+			sample_buffers.Put(buf)
+		}
+	}
+	return make([]int32, n)
+}
+
+// release_samples hands a scratch slice back for reuse, it mustn't be used
+// afterwards
+func release_samples(samples []int32) {
+	if atomic.LoadInt32(&buffer_pool_enabled) == 1 && cap(samples) <= max_pooled_samples {
+		sample_buffers.Put(&samples)
+	}
+}
+
+// samples decodes the interleaved samples of the segment into a scratch
+// buffer, spawn_samples releases it
+func (p *AudioSegment) samples() []int32 {
+	width := int(p.sample_width)
+	data := *p.data
+	samples := sample_buffer(len(data) / width)
+	for i := range samples {
+		samples[i] = decode_sample(data[i*width:], p.sample_width)
+	}
+	return samples
+}
+
+func (p *AudioSegment) encode_samples(samples []int32) []byte {
+	width := int(p.sample_width)
+	data := make([]byte, len(samples)*width)
+	for i, v := range samples {
+		encode_sample(data[i*width:], p.sample_width, v)
+	}
+	return data
+}
+
+func (p *AudioSegment) spawn_samples(samples []int32) *AudioSegment {
+	data := p.encode_samples(samples)
+	release_samples(samples)
+	return p.spawn(&data)
+}
+
+// Map replaces every sample with fn(sample, channel). Results outside the
+// range of the sample width go through the clip mode like any other transform.
+func (p *AudioSegment) Map(fn func(sample int32, channel int) int32) *AudioSegment {
+	channels := int(p.channels)
+	samples := p.samples()
+	for i, v := range samples {
+		samples[i] = p.saturate(int64(fn(v, i%channels)))
+	}
+	return p.spawn_samples(samples)
+}
+
+// own_data gives p a private copy of its data when views of it were made,
+// whether p is one of them or the segment they were cut from
+func (p *AudioSegment) own_data() {
+	if p.owner == nil || atomic.LoadInt32(&p.owner.views) == 0 {
+		return
+	}
+	data := make([]byte, len(*p.data))
+	copy(data, *p.data)
+	p.set_data(&data)
+}
+
+// MapInPlace is Map writing into the data of the segment instead of a copy,
+// and returns it. Segments sharing their data with a slice or view get a
+// private copy first, so the others never see the change; copies of the
+// AudioSegment value itself are not tracked and do. Every result is computed
+// before the first is written, so an ErrClipped panic leaves the data as it
+// was.
+func (p *AudioSegment) MapInPlace(fn func(sample int32, channel int) int32) *AudioSegment {
+	p.own_data()
+	channels := int(p.channels)
+	samples := p.samples()
+	defer release_samples(samples)
+	for i, v := range samples {
+		samples[i] = p.saturate(int64(fn(v, i%channels)))
+	}
+	width := int(p.sample_width)
+	data := *p.data
+	for i, v := range samples {
+		encode_sample(data[i*width:], p.sample_width, v)
+	}
+	return p
+}
+
+func (p *AudioSegment) rms_frames(start, end int) float64 {
+	width := int(p.sample_width)
+	data := (*p.data)[start*int(p.frame_width) : end*int(p.frame_width)]
+	count := len(data) / width
+	if count == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < count; i++ {
+		v := float64(decode_sample(data[i*width:], p.sample_width))
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(count))
+}
+
+func (p *AudioSegment) peak_frames(start, end int) float64 {
+	width := int(p.sample_width)
+	data := (*p.data)[start*int(p.frame_width) : end*int(p.frame_width)]
+	var peak float64
+	for i := 0; i+width <= len(data); i += width {
+		peak = math.Max(peak, math.Abs(float64(decode_sample(data[i:], p.sample_width))))
+	}
+	return peak
+}
+
+func (p *AudioSegment) dbfs_frames(start, end int) float64 {
+	return ratio_to_db(p.rms_frames(start, end) / p.max_possible_amplitude())
+}
+
+// RMS is measured over the whole segment, like pydub's rms but without
+// rounding down to an integer
+func (p *AudioSegment) RMS() float64 {
+	return p.rms_frames(0, p.FrameCount())
+}
+
+// DBFS is the level over the whole segment, see DBFSCompat for pydub's exact
+// value
+func (p *AudioSegment) DBFS() float64 {
+	return p.dbfs_frames(0, p.FrameCount())
+}
+
+// DBFSCompat reproduces pydub's dBFS: the RMS of all samples truncated to an
+// integer, as audioop.rms returns it, relative to the largest amplitude
+func (p *AudioSegment) DBFSCompat() float64 {
+	return ratio_to_db(math.Floor(p.RMS()) / p.max_possible_amplitude())
+}
+
+// WindowedDBFS measures the level of every windowMs window, e.g. 300ms for
+// loudness tools, the last one possibly shorter
+func (p *AudioSegment) WindowedDBFS(windowMs int) []float64 {
+	size := p.FrameCountMs(windowMs)
+	if size < 1 {
+		errmsg := fmt.Sprintf("Measurement window of %dms is too short", windowMs)
+		panic(errmsg)
+	}
+	frames := p.FrameCount()
+	levels := make([]float64, 0, (frames+size-1)/size)
+	for start := 0; start < frames; start += size {
+		end := start + size
+		if end > frames {
+			end = frames
+		}
+		levels = append(levels, p.dbfs_frames(start, end))
+	}
+	return levels
+}
+
+// channel_floats splits the segment into one slice of sample values per channel
+func (p *AudioSegment) channel_floats() [][]float64 {
+	channels := int(p.channels)
+	frames := p.FrameCount()
+	width := int(p.sample_width)
+	data := *p.data
+	out := make([][]float64, channels)
+	for c := range out {
+		out[c] = make([]float64, frames)
+	}
+	for i := 0; i < frames*channels; i++ {
+		out[i%channels][i/channels] = float64(decode_sample(data[i*width:], p.sample_width))
+	}
+	return out
+}
+
+func (p *AudioSegment) spawn_channel_floats(channels [][]float64) *AudioSegment {
+	frames := 0
+	if len(channels) > 0 {
+		frames = len(channels[0])
+	}
+	count := len(channels)
+	samples := sample_buffer(frames * count)
+	for c, values := range channels {
+		for i, v := range values {
+			samples[i*count+c] = p.saturate_float(v)
+		}
+	}
+	return p.spawn_samples(samples)
+}
+
+// GetFloatSamples returns the interleaved samples scaled to -1..1
+func (p *AudioSegment) GetFloatSamples() []float64 {
+	scale := p.max_possible_amplitude()
+	samples := p.samples()
+	out := make([]float64, len(samples))
+	for i, v := range samples {
+		out[i] = float64(v) / scale
+	}
+	release_samples(samples)
+	return out
+}
+
+func FromFloatSamples(samples []float64, channels uint16, frameRate uint32, sampleWidth uint16) *AudioSegment {
+	if sampleWidth < 1 || sampleWidth > 4 {
+		panic(fmt_width_error(sampleWidth))
+	}
+	if channels == 0 || len(samples)%int(channels) != 0 {
+		errmsg := fmt.Sprintf("%d samples can't be split into frames of %d channels", len(samples), channels)
+		panic(errmsg)
+	}
+	obj := &AudioSegment{
+		channels:     channels,
+		frame_rate:   frameRate,
+		sample_width: sampleWidth,
+		frame_width:  channels * sampleWidth,
+	}
+	scale := obj.max_possible_amplitude()
+	values := make([]int32, len(samples))
+	for i, v := range samples {
+		values[i] = obj.clamp_float(v * scale)
+	}
+	data := obj.encode_samples(values)
+	obj.set_data(&data)
+	return obj
+}
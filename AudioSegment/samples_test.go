@@ -0,0 +1,146 @@
+package AudioSegment
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func full_scale_segment() *AudioSegment {
+	return FromFloatSamples([]float64{1, -1, 0.5}, 1, 8000, 2)
+}
+
+func TestConversionClampsInEveryClipMode(t *testing.T) {
+	defer SetClipMode(GetClipMode())
+	for _, mode := range []ClipMode{ClipClamp, ClipWrap, ClipError} {
+		SetClipMode(mode)
+		cases := []struct {
+			name string
+			seg  *AudioSegment
+			want []int32
+		}{
+			{"FromFloatSamples", full_scale_segment(), []int32{32767, -32768, 16384}},
+			{"SetSampleWidth", full_scale_segment().SetSampleWidth(1), []int32{127, -128, 64}},
+			{"dither_to_width", full_scale_segment().dither_to_width(1, 1), nil},
+		}
+		for _, c := range cases {
+			samples := c.seg.samples()
+			for i, v := range samples {
+				if v > int32(c.seg.max_sample()) || v < int32(c.seg.min_sample()) {
+					t.Errorf("mode %d, %s: sample %d out of range", mode, c.name, v)
+				}
+				if c.want != nil && v != c.want[i] {
+					t.Errorf("mode %d, %s: sample %d is %d, want %d", mode, c.name, i, v, c.want[i])
+				}
+			}
+			// a full scale positive sample must stay positive
+			if samples[0] <= 0 {
+				t.Errorf("mode %d, %s: full scale sample became %d", mode, c.name, samples[0])
+			}
+		}
+	}
+}
+
+func TestGainFollowsClipMode(t *testing.T) {
+	defer SetClipMode(GetClipMode())
+	SetClipMode(ClipWrap)
+	if v := full_scale_segment().ApplyGain(6).samples()[0]; v >= 0 {
+		t.Errorf("ClipWrap: boosted sample is %d, expected it to wrap", v)
+	}
+	SetClipMode(ClipError)
+	defer func() {
+		if recover() != ErrClipped {
+			t.Errorf("ClipError: boosting a full scale sample didn't panic with ErrClipped")
+		}
+	}()
+	full_scale_segment().ApplyGain(6)
+}
+
+func TestClippedInPlaceLeavesData(t *testing.T) {
+	defer SetClipMode(GetClipMode())
+	SetClipMode(ClipError)
+	// the first sample fits the gain, the second clips
+	seg := FromFloatSamples([]float64{0.25, 1}, 1, 8000, 2)
+	want := seg.samples()
+	func() {
+		defer func() {
+			if recover() != ErrClipped {
+				t.Errorf("ApplyGainInPlace didn't panic with ErrClipped")
+			}
+		}()
+		seg.ApplyGainInPlace(6)
+	}()
+	for i, v := range seg.samples() {
+		if v != want[i] {
+			t.Errorf("sample %d is %d after the panic, want %d", i, v, want[i])
+		}
+	}
+}
+
+func TestProcessDirReturnsClipped(t *testing.T) {
+	defer SetClipMode(GetClipMode())
+	SetClipMode(ClipError)
+	dir := t.TempDir()
+	full_scale_segment().Export(filepath.Join(dir, "a.wav"), "wav")
+	err := ProcessDir(dir, filepath.Join(dir, "out"), "wav", 1, func(seg *AudioSegment) *AudioSegment {
+		return seg.ApplyGain(12)
+	})
+	batch_err, ok := err.(*BatchError)
+	if !ok || len(batch_err.Errors) != 1 || !errors.Is(batch_err.Errors[0].Err, ErrClipped) {
+		t.Errorf("ProcessDir returned %v, want ErrClipped for a.wav", err)
+	}
+}
+
+// go test -bench GainFadeExport -benchmem compares the allocs/op with and
+// without the buffer pool
+func BenchmarkGainFadeExport(b *testing.B) {
+	seg := SineWave(440, 500, 44100, 2).SetChannels(2)
+	defer SetBufferPool(false)
+	for _, c := range []struct {
+		name string
+		pool bool
+	}{
+		{"pool off", false},
+		{"pool on", true},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			SetBufferPool(c.pool)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := seg.ApplyGain(-3).FadeIn(50).FadeOut(50).encode_wav(ExportOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestBufferPoolSizes(t *testing.T) {
+	defer SetBufferPool(false)
+	SetBufferPool(true)
+	cases := []struct {
+		name string
+		n    int
+	}{
+		{"small", 16},
+		{"larger than the pooled one", 4096},
+		{"smaller again", 100},
+		{"over the cap", max_pooled_samples + 1},
+	}
+	for _, c := range cases {
+		buf := sample_buffer(c.n)
+		if len(buf) != c.n {
+			t.Errorf("%s: buffer of %d samples, want %d", c.name, len(buf), c.n)
+		}
+		release_samples(buf)
+	}
+	for {
+		buf, ok := sample_buffers.Get().(*[]int32)
+		if !ok {
+			break
+		}
+		if cap(*buf) > max_pooled_samples {
+			t.Errorf("the pool kept a buffer of %d samples", cap(*buf))
+		}
+	}
+}
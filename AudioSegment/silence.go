@@ -0,0 +1,75 @@
+package AudioSegment
+
+const silence_chunk_ms = 10
+
+func (p *AudioSegment) LeadingSilence(threshDB float64) int {
+	length := p.Len()
+	t := 0
+	for t < length {
+		start := p.FrameCountMs(t)
+		end := p.FrameCountMs(t + silence_chunk_ms)
+		if end > p.FrameCount() {
+			end = p.FrameCount()
+		}
+		if p.dbfs_frames(start, end) >= threshDB {
+			return t
+		}
+		t += silence_chunk_ms
+	}
+	return length
+}
+
+func (p *AudioSegment) TrailingSilence(threshDB float64) int {
+	length := p.Len()
+	frames := p.FrameCount()
+	t := 0
+	for t < length {
+		start := frames - p.FrameCountMs(t+silence_chunk_ms)
+		if start < 0 {
+			start = 0
+		}
+		if p.dbfs_frames(start, frames-p.FrameCountMs(t)) >= threshDB {
+			return t
+		}
+		t += silence_chunk_ms
+	}
+	return length
+}
+
+// IsSilent reports whether every silence_chunk_ms window stays under threshDB,
+// stopping at the first one that doesn't
+func (p *AudioSegment) IsSilent(threshDB float64) bool {
+	frames := p.FrameCount()
+	size := p.FrameCountMs(silence_chunk_ms)
+	if size < 1 {
+		size = 1
+	}
+	for start := 0; start < frames; start += size {
+		end := start + size
+		if end > frames {
+			end = frames
+		}
+		if p.dbfs_frames(start, end) >= threshDB {
+			return false
+		}
+	}
+	return true
+}
+
+// StripSilence cuts the leading and trailing audio under threshDB, measured
+// in silence_chunk_ms steps
+func (p *AudioSegment) StripSilence(threshDB float64) *AudioSegment {
+	start := p.FrameCountMs(p.LeadingSilence(threshDB))
+	end := p.FrameCount() - p.FrameCountMs(p.TrailingSilence(threshDB))
+	if end < start {
+		end = start
+	}
+	return p.SliceFrames(start, end)
+}
+
+// ExportTrimmed exports the segment without its leading and trailing silence,
+// the segment itself is left as is
+func (p *AudioSegment) ExportTrimmed(out_f string, format string, threshDB float64) (err error) {
+	defer recover_clipped(&err)
+	return p.StripSilence(threshDB).export(out_f, format, ExportOptions{})
+}
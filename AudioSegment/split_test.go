@@ -0,0 +1,37 @@
+package AudioSegment
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitNRecombines(t *testing.T) {
+	stereo := SineWave(440, 1001, 8000, 2).SetChannels(2)
+	cases := []struct {
+		name string
+		seg  *AudioSegment
+		n    int
+	}{
+		{"mono", SineWave(440, 1000, 8000, 2), 4},
+		{"mono remainder", SineWave(440, 1001, 8000, 2), 3},
+		{"stereo remainder", stereo, 7},
+		{"one piece", stereo, 1},
+		{"more pieces than frames", SineWave(440, 1, 8000, 1), 20},
+	}
+	for _, c := range cases {
+		pieces := c.seg.SplitN(c.n)
+		if len(pieces) != c.n {
+			t.Fatalf("%s: %d pieces, want %d", c.name, len(pieces), c.n)
+		}
+		out := pieces[0]
+		for i, piece := range pieces[1:] {
+			if piece.FrameCount() < pieces[0].FrameCount() {
+				t.Errorf("%s: piece %d is shorter than the first", c.name, i+1)
+			}
+			out = out.Append(piece)
+		}
+		if !bytes.Equal(*out.data, *c.seg.data) {
+			t.Errorf("%s: the recombined pieces differ from the segment", c.name)
+		}
+	}
+}
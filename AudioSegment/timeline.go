@@ -0,0 +1,183 @@
+package AudioSegment
+
+import (
+	"fmt"
+)
+
+type TimelineItem struct {
+	Segment *AudioSegment
+	GainDB  float64
+	// overlap with the previous item, ignored on the first one
+	CrossfadeMs int
+}
+
+// common_format is the highest frame rate, channel count and sample width
+// among segs
+func common_format(segs []*AudioSegment) (frame_rate uint32, channels, sample_width uint16) {
+	for _, seg := range segs {
+		if seg.frame_rate > frame_rate {
+			frame_rate = seg.frame_rate
+		}
+		if seg.channels > channels {
+			channels = seg.channels
+		}
+		if seg.sample_width > sample_width {
+			sample_width = seg.sample_width
+		}
+	}
+	return frame_rate, channels, sample_width
+}
+
+// convert_format converts the segment to the given format, only mono can be
+// spread to more channels
+func (p *AudioSegment) convert_format(frame_rate uint32, channels, sample_width uint16) (*AudioSegment, error) {
+	if p.channels != channels && p.channels != 1 {
+		return nil, fmt.Errorf("Can't convert %d channels to %d channels", p.channels, channels)
+	}
+	seg := p
+	if seg.channels != channels {
+		seg = seg.SetChannels(channels)
+	}
+	if seg.sample_width != sample_width {
+		seg = seg.SetSampleWidth(sample_width)
+	}
+	if seg.frame_rate != frame_rate {
+		seg = seg.SetFrameRate(frame_rate)
+	}
+	return seg, nil
+}
+
+// sync_segments converts segs to the highest frame rate, channel count and
+// sample width among them
+func sync_segments(segs []*AudioSegment) ([]*AudioSegment, error) {
+	frame_rate, channels, sample_width := common_format(segs)
+	synced := make([]*AudioSegment, len(segs))
+	for i, seg := range segs {
+		seg, err := seg.convert_format(frame_rate, channels, sample_width)
+		if err != nil {
+			return nil, err
+		}
+		synced[i] = seg
+	}
+	return synced, nil
+}
+
+// ConcatAll joins segs end to end after converting them to the highest frame
+// rate, channel count and sample width among them, which the result carries:
+// a 16-bit clip joined with a 24-bit one is promoted to 24 bits, and the
+// chosen width is the SampleWidth of the result
+func ConcatAll(segs []*AudioSegment) (*AudioSegment, error) {
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("No AudioSegments to concatenate")
+	}
+	for i, seg := range segs {
+		if seg == nil || seg.data == nil {
+			return nil, fmt.Errorf("AudioSegment %d is empty", i)
+		}
+	}
+	segs, err := sync_segments(segs)
+	if err != nil {
+		return nil, err
+	}
+	builder := NewBuilder()
+	for _, seg := range segs {
+		builder.AddSegment(seg)
+	}
+	return builder.Build()
+}
+
+// AssembleTimeline joins items in order, applying the gain of each and
+// crossfading it with the previous one. The items are first converted to a
+// common format; the crossfades into and out of an item must fit in it.
+func AssembleTimeline(items []TimelineItem) (*AudioSegment, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("Timeline has no items")
+	}
+	segs := make([]*AudioSegment, len(items))
+	for i, item := range items {
+		if item.Segment == nil || item.Segment.data == nil {
+			return nil, fmt.Errorf("Timeline item %d: empty AudioSegment", i)
+		}
+		if i > 0 && item.CrossfadeMs < 0 {
+			return nil, fmt.Errorf("Timeline item %d: invalid crossfade %dms", i, item.CrossfadeMs)
+		}
+		segs[i] = item.Segment
+	}
+	segs, err := sync_segments(segs)
+	if err != nil {
+		return nil, fmt.Errorf("Timeline: %v", err)
+	}
+
+	for i := 1; i < len(items); i++ {
+		crossfade := items[i].CrossfadeMs
+		available := segs[i-1].Len()
+		if i > 1 {
+			available -= items[i-1].CrossfadeMs
+		}
+		if crossfade > available {
+			return nil, fmt.Errorf("Timeline item %d: crossfade of %dms overlaps the previous item, %dms available", i, crossfade, available)
+		}
+		if crossfade > segs[i].Len() {
+			return nil, fmt.Errorf("Timeline item %d: crossfade of %dms is longer than the item (%dms)", i, crossfade, segs[i].Len())
+		}
+	}
+
+	var out *AudioSegment
+	for i, seg := range segs {
+		if items[i].GainDB != 0 {
+			seg = seg.ApplyGain(items[i].GainDB)
+		}
+		if i == 0 {
+			out = seg
+			continue
+		}
+		if out, err = out.AppendCrossfade(seg, items[i].CrossfadeMs); err != nil {
+			return nil, fmt.Errorf("Timeline item %d: %v", i, err)
+		}
+	}
+	return out, nil
+}
+
+// BeatMix crossfades clips into one continuous track, see BeatMixWithOptions
+func BeatMix(clips []*AudioSegment, crossfadeMs int) (*AudioSegment, error) {
+	return BeatMixWithOptions(clips, crossfadeMs, CrossfadeOptions{})
+}
+
+// BeatMixWithOptions converts clips to a common format and crossfades each
+// into the next over crossfadeMs, shortened where a clip is too short to hold
+// both its joins. opts.AutoSync lines up every join by correlation.
+func BeatMixWithOptions(clips []*AudioSegment, crossfadeMs int, opts CrossfadeOptions) (*AudioSegment, error) {
+	if len(clips) == 0 {
+		return nil, fmt.Errorf("No clips to mix")
+	}
+	if crossfadeMs < 0 {
+		return nil, fmt.Errorf("Invalid crossfade %dms", crossfadeMs)
+	}
+	for i, clip := range clips {
+		if clip == nil || clip.data == nil {
+			return nil, fmt.Errorf("Clip %d: empty AudioSegment", i)
+		}
+	}
+	clips, err := sync_segments(clips)
+	if err != nil {
+		return nil, err
+	}
+
+	out := clips[0]
+	// the tail of the previous clip the last crossfade didn't use
+	available := clips[0].Len()
+	for i, clip := range clips[1:] {
+		crossfade := crossfadeMs
+		if crossfade > available {
+			crossfade = available
+		}
+		if crossfade > clip.Len() {
+			crossfade = clip.Len()
+		}
+		if out, err = out.AppendCrossfadeWithOptions(clip, crossfade, opts); err != nil {
+			return nil, fmt.Errorf("Clip %d: %v", i+1, err)
+		}
+		available = clip.Len() - crossfade
+	}
+	return out, nil
+}
@@ -0,0 +1,49 @@
+package AudioSegment
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConcatAllPromotesSampleWidth(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "24.wav")
+	SineWave(440, 50, 8000, 3).Export(file, "wav")
+	clip16 := SineWave(440, 50, 8000, 2)
+	clip24 := From_file(file, "wav")
+	cases := []struct {
+		name string
+		segs []*AudioSegment
+	}{
+		{"16-bit first", []*AudioSegment{clip16, clip24}},
+		{"24-bit first", []*AudioSegment{clip24, clip16}},
+	}
+	for _, c := range cases {
+		out, err := ConcatAll(c.segs)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if out.SampleWidth() != 3 {
+			t.Errorf("%s: sample width %d, want 3", c.name, out.SampleWidth())
+		}
+		// 16-bit samples move up 8 bits, 24-bit ones are kept
+		want := make([]int32, 0, out.NumSamples())
+		for _, seg := range c.segs {
+			for _, v := range seg.samples() {
+				if seg.sample_width == 2 {
+					v <<= 8
+				}
+				want = append(want, v)
+			}
+		}
+		got := out.samples()
+		if len(got) != len(want) {
+			t.Fatalf("%s: %d samples, want %d", c.name, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: sample %d is %d, want %d", c.name, i, got[i], want[i])
+				break
+			}
+		}
+	}
+}
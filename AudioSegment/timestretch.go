@@ -0,0 +1,158 @@
+package AudioSegment
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	stretch_frame_ms     = 40
+	stretch_tolerance_ms = 10
+	// the coarse alignment search runs on the mono mix averaged down to about
+	// this rate before being refined at the full rate
+	stretch_search_rate = 4000
+)
+
+// wsola_search finds the position within tolerance of nominal whose frame of
+// size best correlates with the one at natural. The whole range is searched on
+// coarse, mono averaged over blocks of step frames, and only the neighbourhood
+// of the coarse best at full resolution, which cuts the work by about step².
+func wsola_search(mono, coarse []float64, step, nominal, natural, tolerance, size int) int {
+	sample := func(x []float64, i int) float64 {
+		if i < 0 || i >= len(x) {
+			return 0
+		}
+		return x[i]
+	}
+	correlate := func(x []float64, a, b, n int) float64 {
+		var corr float64
+		for i := 0; i < n; i++ {
+			corr += sample(x, a+i) * sample(x, b+i)
+		}
+		return corr
+	}
+	best_in := func(x []float64, from, to, target, n, scale int) int {
+		pos, best := -1, math.Inf(-1)
+		for candidate := from; candidate <= to; candidate++ {
+			if candidate*scale < 0 || candidate*scale >= len(mono) {
+				continue
+			}
+			if corr := correlate(x, candidate, target, n); corr > best {
+				best, pos = corr, candidate
+			}
+		}
+		return pos
+	}
+
+	low, high := nominal-tolerance, nominal+tolerance
+	if step > 1 {
+		rough := best_in(coarse, low/step, high/step, natural/step, size/step, step)
+		if rough < 0 {
+			return nominal
+		}
+		if rough*step-step > low {
+			low = rough*step - step
+		}
+		if rough*step+step < high {
+			high = rough*step + step
+		}
+	}
+	if pos := best_in(mono, low, high, natural, size, 1); pos >= 0 {
+		return pos
+	}
+	return nominal
+}
+
+// TimeStretch changes the duration of the segment by factor (2 doubles it,
+// 0.5 halves it) without changing the pitch, using WSOLA: frames are read at
+// the stretched rate and each one is shifted within a small tolerance to the
+// position that best continues the previously written frame before being
+// overlap-added.
+func (p *AudioSegment) TimeStretch(factor float64) *AudioSegment {
+	if factor <= 0 {
+		errmsg := fmt.Sprintf("Invalid time stretch factor %g", factor)
+		panic(errmsg)
+	}
+	in_frames := p.FrameCount()
+	out_frames := int(math.Round(float64(in_frames) * factor))
+	channels := p.channel_floats()
+	if factor == 1 || in_frames == 0 {
+		return p.spawn_channel_floats(channels)
+	}
+
+	size := p.FrameCountMs(stretch_frame_ms)
+	if size < 4 {
+		size = 4
+	}
+	synthesis_hop := size / 2
+	analysis_hop := float64(synthesis_hop) / factor
+	tolerance := p.FrameCountMs(stretch_tolerance_ms)
+
+	// alignment is decided on the mono mix and applied to every channel
+	mono := make([]float64, in_frames)
+	for _, samples := range channels {
+		for i, v := range samples {
+			mono[i] += v
+		}
+	}
+	at := func(samples []float64, i int) float64 {
+		if i < 0 || i >= len(samples) {
+			return 0
+		}
+		return samples[i]
+	}
+	step := int(p.frame_rate) / stretch_search_rate
+	if step < 1 {
+		step = 1
+	}
+	coarse := make([]float64, (in_frames+step-1)/step)
+	for i, v := range mono {
+		coarse[i/step] += v / float64(step)
+	}
+
+	window := make([]float64, size)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size))
+	}
+
+	out := make([][]float64, len(channels))
+	for c := range out {
+		out[c] = make([]float64, out_frames+size)
+	}
+	weights := make([]float64, out_frames+size)
+
+	prev := 0
+	for k := 0; k*synthesis_hop < out_frames; k++ {
+		pos := 0
+		if k > 0 {
+			nominal := int(math.Round(float64(k) * analysis_hop))
+			pos = wsola_search(mono, coarse, step, nominal, prev+synthesis_hop, tolerance, size)
+		}
+
+		offset := k * synthesis_hop
+		for i := 0; i < size; i++ {
+			for c, samples := range channels {
+				out[c][offset+i] += window[i] * at(samples, pos+i)
+			}
+			weights[offset+i] += window[i]
+		}
+		prev = pos
+	}
+
+	for c := range out {
+		for i := 0; i < out_frames; i++ {
+			if weights[i] > 1e-6 {
+				out[c][i] /= weights[i]
+			}
+		}
+		out[c] = out[c][:out_frames]
+	}
+	return p.scale_loop(p.spawn_channel_floats(out))
+}
+
+// PitchShift moves the pitch by semitones keeping the duration: the segment is
+// stretched by 2^(semitones/12) and resampled back to its original length.
+func (p *AudioSegment) PitchShift(semitones float64) *AudioSegment {
+	ratio := math.Pow(2, semitones/12)
+	return p.TimeStretch(ratio).resample_frames(p.FrameCount())
+}
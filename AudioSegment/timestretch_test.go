@@ -0,0 +1,51 @@
+package AudioSegment
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTimeStretchScalesDuration(t *testing.T) {
+	src := SineWave(440, 1000, 16000, 2)
+	for _, factor := range []float64{0.5, 0.9, 1.25, 2} {
+		out := src.TimeStretch(factor)
+		want := int(math.Round(float64(src.FrameCount()) * factor))
+		if out.FrameCount() != want {
+			t.Errorf("factor %g: %d frames, want %d", factor, out.FrameCount(), want)
+		}
+		// the pitch stays where it was
+		if f := out.DominantFrequency(); math.Abs(f-440) > 10 {
+			t.Errorf("factor %g: dominant frequency is %.1fHz, want 440Hz", factor, f)
+		}
+	}
+}
+
+func TestPitchShiftKeepsDuration(t *testing.T) {
+	src := SineWave(440, 1000, 16000, 2)
+	cases := []struct {
+		semitones float64
+		want      float64
+	}{
+		{12, 880},
+		{-12, 220},
+		{7, 440 * math.Pow(2, 7.0/12)},
+		{0, 440},
+	}
+	for _, c := range cases {
+		out := src.PitchShift(c.semitones)
+		if out.FrameCount() != src.FrameCount() {
+			t.Errorf("%g semitones: %d frames, want %d", c.semitones, out.FrameCount(), src.FrameCount())
+		}
+		if f := out.DominantFrequency(); math.Abs(f-c.want) > c.want*0.02 {
+			t.Errorf("%g semitones: dominant frequency is %.1fHz, want %.1fHz", c.semitones, f, c.want)
+		}
+	}
+}
+
+func BenchmarkTimeStretch(b *testing.B) {
+	seg := SineWave(440, 10000, 44100, 2).Overlay(Chirp(200, 4000, 10000, 44100, 2, LinearSweep).ApplyGain(-6))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		seg.TimeStretch(0.9)
+	}
+}
@@ -0,0 +1,54 @@
+package AudioSegment
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestInPlaceLeavesViewsAlone(t *testing.T) {
+	negate := func(sample int32, channel int) int32 { return -sample }
+	cases := []struct {
+		name   string
+		mutate func(parent, view *AudioSegment)
+	}{
+		{"parent changed", func(parent, view *AudioSegment) { parent.MapInPlace(negate) }},
+		{"view changed", func(parent, view *AudioSegment) { view.MapInPlace(negate) }},
+	}
+	for _, c := range cases {
+		parent := SineWave(440, 100, 8000, 2)
+		view := parent.SliceFrames(0, 400)
+		parent_before, view_before := parent.samples(), view.samples()
+		c.mutate(parent, view)
+		parent_after, view_after := parent.samples(), view.samples()
+		changed_parent := parent_after[3] != parent_before[3]
+		changed_view := view_after[3] != view_before[3]
+		if changed_parent == changed_view {
+			t.Errorf("%s: parent changed %v, view changed %v", c.name, changed_parent, changed_view)
+		}
+	}
+}
+
+func TestInPlaceWithoutViewsKeepsData(t *testing.T) {
+	seg := SineWave(440, 100, 8000, 2)
+	data := seg.data
+	seg.ApplyGainInPlace(-6)
+	if seg.data != data {
+		t.Errorf("unshared data was copied")
+	}
+}
+
+// run with -race: slicing must not write the segment being sliced
+func TestConcurrentSlices(t *testing.T) {
+	seg := SineWave(440, 100, 8000, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seg.SliceFrames(0, 100)
+			seg.SplitN(2)
+			seg.Slice(0, 10)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,217 @@
+package AudioSegment
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+)
+
+var ksdataformat_subtype_pcm = []byte{
+	0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00,
+	0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71,
+}
+
+func write_wav_chunk(w io.Writer, order binary.ByteOrder, id []byte, data []byte) error {
+	header := make([]byte, 8)
+	copy(header, id)
+	order.PutUint32(header[4:], uint32(len(data)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if len(data)%2 == 1 {
+		// chunks are word aligned
+		if _, err := w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *AudioSegment) fmt_chunk(order binary.ByteOrder, format_tag uint16, float bool) ([]byte, error) {
+	if format_tag == 0 {
+		format_tag = WavFormatPCM
+		if float {
+			format_tag = WavFormatFloat
+		}
+	}
+	size := 16
+	switch {
+	case format_tag == WavFormatPCM && !float:
+	case format_tag == WavFormatFloat && float:
+		size = 18
+	case (format_tag == WavFormatALaw || format_tag == WavFormatMuLaw) && !float:
+		size = 18
+	case format_tag == WavFormatExtensible:
+		size = 40
+	default:
+		return nil, fmt.Errorf("Unsupported wav format tag 0x%X", format_tag)
+	}
+
+	chunk := make([]byte, size)
+	order.PutUint16(chunk[0:2], format_tag)
+	order.PutUint16(chunk[2:4], p.channels)
+	order.PutUint32(chunk[4:8], p.frame_rate)
+	order.PutUint32(chunk[8:12], p.frame_rate*uint32(p.frame_width))
+	order.PutUint16(chunk[12:14], p.frame_width)
+	order.PutUint16(chunk[14:16], p.sample_width*8)
+	if format_tag == WavFormatExtensible {
+		var channel_mask uint32
+		switch p.channels {
+		case 1:
+			channel_mask = 0x4
+		case 2:
+			channel_mask = 0x3
+		}
+		order.PutUint16(chunk[16:18], 22)
+		order.PutUint16(chunk[18:20], p.sample_width*8)
+		order.PutUint32(chunk[20:24], channel_mask)
+		copy(chunk[24:40], ksdataformat_subtype_pcm)
+		if float {
+			order.PutUint16(chunk[24:26], WavFormatFloat)
+		}
+	}
+	return chunk, nil
+}
+
+// float_segment converts the samples to 32-bit IEEE floats in -1..1, the
+// result is only meant to be written out as a float wav
+func (p *AudioSegment) float_segment() *AudioSegment {
+	samples := p.GetFloatSamples()
+	data := make([]byte, len(samples)*4)
+	for i, v := range samples {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(float32(v)))
+	}
+	as := p.spawn(&data)
+	as.sample_width = 4
+	as.frame_width = p.channels * 4
+	return as
+}
+
+// dither_to_width requantizes to a smaller sample width adding triangular
+// dither of one step and feeding each channel's quantization error back into
+// its next sample, which moves the noise towards high frequencies
+func (p *AudioSegment) dither_to_width(width uint16, seed int64) *AudioSegment {
+	as := p.spawn(p.data)
+	as.sample_width = width
+	as.frame_width = p.channels * width
+	noise := rand.New(rand.NewSource(seed))
+	scale := as.max_possible_amplitude() / p.max_possible_amplitude()
+	channels := int(p.channels)
+	feedback := make([]float64, channels)
+	samples := p.samples()
+	for i, v := range samples {
+		c := i % channels
+		x := float64(v)*scale - feedback[c]
+		q := math.Round(x + noise.Float64() - noise.Float64())
+		feedback[c] = q - x
+		samples[i] = as.clamp_float(q)
+	}
+	return as.spawn_samples(samples)
+}
+
+// big_endian_data swaps every sample of the segment to big endian for RIFX
+func (p *AudioSegment) big_endian_data() []byte {
+	width := int(p.sample_width)
+	data := make([]byte, len(*p.data))
+	copy(data, *p.data)
+	if width == 1 {
+		return data
+	}
+	for i := 0; i+width <= len(data); i += width {
+		for a, b := i, i+width-1; a < b; a, b = a+1, b-1 {
+			data[a], data[b] = data[b], data[a]
+		}
+	}
+	return data
+}
+
+func (p *AudioSegment) saveWav(w io.Writer, opts ExportOptions) error {
+	if opts.FloatOutput {
+		p = p.float_segment()
+	}
+	g711 := opts.FormatTag == WavFormatALaw || opts.FormatTag == WavFormatMuLaw
+	if g711 && !opts.FloatOutput {
+		p = p.g711_data(opts.FormatTag)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	riff_id := "RIFF"
+	audio_data := *p.data
+	if opts.BigEndian {
+		order = binary.BigEndian
+		riff_id = "RIFX"
+		audio_data = p.big_endian_data()
+	}
+
+	fmt_chunk, err := p.fmt_chunk(order, opts.FormatTag, opts.FloatOutput)
+	if err != nil {
+		return err
+	}
+	chunks := []WavChunk{{id: []byte{'f', 'm', 't', ' '}, data: fmt_chunk}}
+	smpl_chunk := p.smpl_chunk(order)
+	// a preserved fact chunk would hold the length of the source, so it is
+	// always regenerated; companded formats require one
+	write_fact := opts.WriteFactChunk || g711 || (opts.PreserveChunks && p.find_chunk("fact") != nil)
+	if write_fact {
+		fact_chunk := make([]byte, 4)
+		order.PutUint32(fact_chunk, uint32(p.FrameCount()))
+		chunks = append(chunks, WavChunk{id: []byte{'f', 'a', 'c', 't'}, data: fact_chunk})
+	}
+	if opts.PreserveChunks {
+		for _, chunk := range p.chunks {
+			// the positions in these chunks are those of the source, which may
+			// have moved or gone: smpl and cue are written from the loop
+			// points and markers of the segment, inst is dropped
+			switch string(chunk.id) {
+			case "smpl", "cue ", "inst":
+				continue
+			}
+			if bytes.Equal(chunk.id, []byte{'f', 'a', 'c', 't'}) {
+				continue
+			}
+			if len(opts.InfoTags) > 0 && is_info_list(chunk) {
+				continue
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+	if len(opts.InfoTags) > 0 {
+		info, err := info_chunk(order, opts.InfoTags)
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, WavChunk{id: []byte{'L', 'I', 'S', 'T'}, data: info})
+	}
+	if opts.PreserveChunks {
+		if cue_chunk := p.cue_chunk(order); cue_chunk != nil {
+			chunks = append(chunks, WavChunk{id: []byte{'c', 'u', 'e', ' '}, data: cue_chunk})
+		}
+	}
+	if smpl_chunk != nil {
+		chunks = append(chunks, WavChunk{id: []byte{'s', 'm', 'p', 'l'}, data: smpl_chunk})
+	}
+	chunks = append(chunks, WavChunk{id: []byte{'d', 'a', 't', 'a'}, data: audio_data})
+
+	riff_size := 4
+	for _, chunk := range chunks {
+		riff_size += 8 + len(chunk.data) + len(chunk.data)%2
+	}
+	header := make([]byte, 12)
+	copy(header[0:4], riff_id)
+	order.PutUint32(header[4:8], uint32(riff_size))
+	copy(header[8:12], "WAVE")
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if err := write_wav_chunk(w, order, chunk.id, chunk.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
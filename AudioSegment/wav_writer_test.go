@@ -0,0 +1,122 @@
+package AudioSegment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFloatWavRoundTrip(t *testing.T) {
+	cases := []struct {
+		width uint16
+		// float32 keeps 24 bits, 32-bit samples lose their low bits
+		tolerance int32
+	}{
+		{1, 0},
+		{2, 0},
+		{4, 128},
+	}
+	for _, c := range cases {
+		src := SineWave(440, 100, 8000, c.width).Overlay(FromFloatSamples([]float64{1, -1}, 1, 8000, c.width))
+		wav, err := src.encode_wav(ExportOptions{FloatOutput: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(t.TempDir(), "float.wav")
+		if err := os.WriteFile(file, wav, 0644); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := from_file(file, "wav")
+		if err != nil {
+			t.Fatalf("%d bytes: %v", c.width, err)
+		}
+		out := loaded.SetSampleWidth(c.width)
+		want, got := src.samples(), out.samples()
+		if len(got) != len(want) {
+			t.Fatalf("%d bytes: %d samples, want %d", c.width, len(got), len(want))
+		}
+		for i := range want {
+			if d := got[i] - want[i]; d > c.tolerance || d < -c.tolerance {
+				t.Errorf("%d bytes: sample %d is %d, want %d", c.width, i, got[i], want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestLoad24BitWav(t *testing.T) {
+	dir := t.TempDir()
+	src := SineWave(440, 100, 48000, 3)
+	file := filepath.Join(dir, "24.wav")
+	src.Export(file, "wav")
+	cases := []struct {
+		name  string
+		opts  ExportOptions
+		width uint16
+		// dithering adds up to a couple of steps of the narrower width
+		tolerance int32
+	}{
+		{"24-bit", ExportOptions{}, 3, 0},
+		{"mastered to 16-bit", ExportOptions{DitherToWidth: 2}, 2, 2},
+	}
+	for _, c := range cases {
+		seg, err := from_file(file, "wav")
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		out := filepath.Join(dir, "out.wav")
+		seg.ExportWithOptions(out, "wav", c.opts)
+		back, err := from_file(out, "wav")
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if back.sample_width != c.width || back.FrameCount() != src.FrameCount() {
+			t.Errorf("%s: %d bytes, %d frames, want %d bytes, %d frames", c.name, back.sample_width, back.FrameCount(), c.width, src.FrameCount())
+			continue
+		}
+		want := src.SetSampleWidth(c.width).samples()
+		for i, v := range back.samples() {
+			if d := v - want[i]; d > c.tolerance || d < -c.tolerance {
+				t.Errorf("%s: sample %d is %d, want %d", c.name, i, v, want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestExportWithinSizePrefersRate(t *testing.T) {
+	// one second of 44.1kHz 16-bit mono is 88200 bytes after the 44 of headers
+	seg := SineWave(440, 1000, 44100, 2)
+	cases := []struct {
+		name     string
+		maxBytes int64
+		rate     uint32
+		width    uint16
+	}{
+		{"fits as is", 88244, 44100, 2},
+		{"8-bit at the same rate", 44144, 44100, 1},
+		{"8-bit at 32kHz before 16-bit at 16kHz", 44143, 32000, 1},
+		{"8kHz 8-bit", 8044, 8000, 1},
+		{"too small", 8043, 0, 0},
+	}
+	for _, c := range cases {
+		out := filepath.Join(t.TempDir(), "out.wav")
+		err := seg.ExportWithinSize(out, c.maxBytes)
+		if c.rate == 0 {
+			if err == nil {
+				t.Errorf("%s: no error for %d bytes", c.name, c.maxBytes)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		back, err := from_file(out, "wav")
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if back.frame_rate != c.rate || back.sample_width != c.width {
+			t.Errorf("%s: %dHz %d bytes, want %dHz %d bytes", c.name, back.frame_rate, back.sample_width, c.rate, c.width)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package AudioSegment
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+)
+
+type WaveformOptions struct {
+	// transparent and black when nil
+	Background color.Color
+	Foreground color.Color
+}
+
+// RenderWaveform draws the peak waveform of the segment into a width x height
+// PNG or SVG image, chosen by the extension of out. Every channel gets a lane
+// of its own.
+func (p *AudioSegment) RenderWaveform(out string, width, height int) error {
+	return p.RenderWaveformWithOptions(out, width, height, WaveformOptions{})
+}
+
+func (p *AudioSegment) RenderWaveformWithOptions(out string, width, height int, opts WaveformOptions) error {
+	if width < 1 || height < 1 {
+		return fmt.Errorf("Invalid waveform size %dx%d", width, height)
+	}
+	format := format_from_path(out)
+	if format != "png" && format != "svg" {
+		return fmt.Errorf("Unsupported waveform format %q", format)
+	}
+	if opts.Background == nil {
+		opts.Background = color.Transparent
+	}
+	if opts.Foreground == nil {
+		opts.Foreground = color.Black
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	bars := p.waveform_bars(width, height)
+	if format == "png" {
+		err = png.Encode(w, waveform_image(bars, width, height, opts))
+	} else {
+		err = write_waveform_svg(w, bars, width, height, opts)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// waveform_bars returns the top and bottom row of every column, centered on
+// the lane of each channel
+func (p *AudioSegment) waveform_bars(width, height int) [][2]int {
+	profile := p.PeakProfile(width)
+	lane := float64(height) / float64(len(profile))
+	bars := make([][2]int, 0, width*len(profile))
+	for c, peaks := range profile {
+		center := lane * (float64(c) + 0.5)
+		for _, peak := range peaks {
+			half := peak * lane / 2
+			top, bottom := int(center-half), int(center+half)
+			// keep silence visible as a centre line
+			if bottom <= top {
+				bottom = top + 1
+			}
+			bars = append(bars, [2]int{top, bottom})
+		}
+	}
+	return bars
+}
+
+func waveform_image(bars [][2]int, width, height int, opts WaveformOptions) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, opts.Background)
+		}
+	}
+	for i, bar := range bars {
+		for y := bar[0]; y < bar[1] && y < height; y++ {
+			img.Set(i%width, y, opts.Foreground)
+		}
+	}
+	return img
+}
+
+func svg_color(c color.Color) (string, float64) {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return "none", 0
+	}
+	// RGBA is alpha premultiplied
+	return fmt.Sprintf("#%02x%02x%02x", r*0xff/a, g*0xff/a, b*0xff/a), float64(a) / 0xffff
+}
+
+func write_waveform_svg(w *bufio.Writer, bars [][2]int, width, height int, opts WaveformOptions) error {
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+	if fill, opacity := svg_color(opts.Background); opacity > 0 {
+		fmt.Fprintf(w, "<rect width=\"%d\" height=\"%d\" fill=\"%s\" fill-opacity=\"%g\"/>\n", width, height, fill, opacity)
+	}
+	fill, opacity := svg_color(opts.Foreground)
+	fmt.Fprintf(w, "<path fill=\"%s\" fill-opacity=\"%g\" d=\"", fill, opacity)
+	for i, bar := range bars {
+		fmt.Fprintf(w, "M%d %dh1v%dh-1z", i%width, bar[0], bar[1]-bar[0])
+	}
+	_, err := fmt.Fprint(w, "\"/>\n</svg>\n")
+	return err
+}